@@ -2,6 +2,7 @@ package v1
 
 import (
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,6 +29,269 @@ type UpdateServiceSpec struct {
 	// +kubebuilder:validation:Required
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	GraphDataImage string `json:"graphDataImage"`
+
+	// graphDataImageVerification, when set, requires every graph-data image
+	// digest to carry a valid signature before it is rolled out. Digests that
+	// fail verification are not deployed, and the previously verified digest
+	// keeps being served.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	GraphDataImageVerification *GraphDataImageVerification `json:"graphDataImageVerification,omitempty"`
+
+	// graphDataHistoryLimit is the number of resolved graph-data image digests
+	// to keep in status.graphDataHistory. Defaults to 10. The latest, pinned
+	// and served digests are always kept.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=50
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	GraphDataHistoryLimit int32 `json:"graphDataHistoryLimit,omitempty"`
+
+	// rolloutSchedule, when set, restricts changes to the Deployment's pod
+	// template, such as a new graph-data digest or graph-builder
+	// configuration, to maintenance windows. Outside a window, pending
+	// changes are reported by the RolloutPending condition.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	RolloutSchedule *RolloutSchedule `json:"rolloutSchedule,omitempty"`
+
+	// expectedChannels lists update channels, such as stable-4.16, which the
+	// policy engine is expected to serve. When set, the operator queries the
+	// policy engine for each channel after rollouts and periodically, and
+	// reports the result in status.graphServing and the GraphServing
+	// condition.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ExpectedChannels []string `json:"expectedChannels,omitempty"`
+
+	// scrapeFailureThreshold is how long graph-builder scraping may fail
+	// before the UpdateService is reported Degraded. Defaults to 30m.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ScrapeFailureThreshold *metav1.Duration `json:"scrapeFailureThreshold,omitempty"`
+
+	// alerts overrides the thresholds of the alerts in the PrometheusRule
+	// which the operator creates for the UpdateService when the cluster
+	// monitoring CRDs exist. The graph-builder scraping alert uses
+	// scrapeFailureThreshold.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Alerts *AlertThresholds `json:"alerts,omitempty"`
+
+	// managementState is whether the operator manages the resources of the
+	// UpdateService. When Unmanaged, the operator stops changing them, for
+	// example so that the Deployment can be patched by hand during an
+	// incident, but keeps reporting their status. When Removed, the operator
+	// deletes them but keeps the UpdateService and its configuration.
+	// Defaults to Managed.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Managed
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ManagementState ManagementState `json:"managementState,omitempty"`
+
+	// operandImage is the Cincinnati image of the graph-builder and policy
+	// engine containers. Defaults to the operandImage of the
+	// UpdateServiceOperatorConfig, and to the operand image of the operator.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	OperandImage string `json:"operandImage,omitempty"`
+
+	// resources are the compute resources of the graph-builder and policy
+	// engine containers. Each container defaults to the resources of the
+	// UpdateServiceOperatorConfig, and to requests of 150m CPU and 64Mi of
+	// memory with limits of 750m CPU and 512Mi of memory.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Resources *OperandResources `json:"resources,omitempty"`
+
+	// exposure is how the policy engine is exposed. Defaults to the exposure
+	// of the UpdateServiceOperatorConfig, and to Route.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Exposure ExposureMode `json:"exposure,omitempty"`
+
+	// proxy is the proxy through which the graph builder reaches the release
+	// registry. Defaults to the cluster-wide proxy.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Proxy *ProxyConfig `json:"proxy,omitempty"`
+}
+
+// ProxyConfig is the proxy of the graph builder.
+// +kubebuilder:validation:XValidation:rule="self.mode == 'Custom' || !(has(self.httpProxy) || has(self.httpsProxy) || has(self.noProxy))",message="httpProxy, httpsProxy and noProxy are only allowed with the Custom mode"
+type ProxyConfig struct {
+	// mode is Cluster to use the cluster-wide proxy, Custom to use the
+	// proxy of httpProxy, httpsProxy and noProxy, or None to use no proxy.
+	// Defaults to Cluster.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Cluster
+	Mode ProxyMode `json:"mode,omitempty"`
+
+	// httpProxy is the URL of the proxy for HTTP requests.
+	// +kubebuilder:validation:Optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// httpsProxy is the URL of the proxy for HTTPS requests.
+	// +kubebuilder:validation:Optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// noProxy is a comma-separated list of hostnames, domains prefixed with
+	// a dot, and CIDRs which are not reached through the proxy.
+	// +kubebuilder:validation:Optional
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// ProxyMode is which proxy the graph builder of an UpdateService uses.
+// +kubebuilder:validation:Enum=Cluster;Custom;None
+type ProxyMode string
+
+const (
+	// ProxyCluster uses the cluster-wide proxy of the Proxy named cluster.
+	ProxyCluster ProxyMode = "Cluster"
+	// ProxyCustom uses the proxy of the ProxyConfig.
+	ProxyCustom ProxyMode = "Custom"
+	// ProxyNone uses no proxy.
+	ProxyNone ProxyMode = "None"
+)
+
+// OperandResources are the compute resources of the operand containers.
+type OperandResources struct {
+	// graphBuilder are the compute resources of the graph-builder container.
+	// +kubebuilder:validation:Optional
+	GraphBuilder *corev1.ResourceRequirements `json:"graphBuilder,omitempty"`
+
+	// policyEngine are the compute resources of the policy engine container.
+	// +kubebuilder:validation:Optional
+	PolicyEngine *corev1.ResourceRequirements `json:"policyEngine,omitempty"`
+}
+
+// ExposureMode is how the policy engine of an UpdateService is exposed.
+// +kubebuilder:validation:Enum=Route;None
+type ExposureMode string
+
+const (
+	// ExposureRoute exposes the policy engine outside of the cluster, through
+	// a Route.
+	ExposureRoute ExposureMode = "Route"
+	// ExposureNone exposes the policy engine inside of the cluster only,
+	// through its Service.
+	ExposureNone ExposureMode = "None"
+)
+
+// ManagementState is whether the operator manages the resources of an
+// UpdateService.
+// +kubebuilder:validation:Enum=Managed;Unmanaged;Removed
+type ManagementState string
+
+const (
+	// ManagementStateManaged reconciles the resources of the UpdateService.
+	ManagementStateManaged ManagementState = "Managed"
+	// ManagementStateUnmanaged leaves the resources of the UpdateService as
+	// they are, and only reports their status.
+	ManagementStateUnmanaged ManagementState = "Unmanaged"
+	// ManagementStateRemoved deletes the resources of the UpdateService.
+	ManagementStateRemoved ManagementState = "Removed"
+)
+
+// AlertThresholds are the thresholds of the UpdateService alerts.
+type AlertThresholds struct {
+	// policyEngineUnavailableFor is how long the policy engine may have no
+	// ready replicas before the UpdateServicePolicyEngineUnavailable alert
+	// fires. Defaults to 5m.
+	// +kubebuilder:validation:Optional
+	PolicyEngineUnavailableFor *metav1.Duration `json:"policyEngineUnavailableFor,omitempty"`
+
+	// graphDataMaxAge is how long the graph-data digest may stay unchanged
+	// before the UpdateServiceGraphDataStale alert fires. Defaults to 168h.
+	// +kubebuilder:validation:Optional
+	GraphDataMaxAge *metav1.Duration `json:"graphDataMaxAge,omitempty"`
+
+	// routeNotAdmittedFor is how long the policy engine Route may have no
+	// admitted ingress before the UpdateServiceRouteNotAdmitted alert fires.
+	// Defaults to 10m.
+	// +kubebuilder:validation:Optional
+	RouteNotAdmittedFor *metav1.Duration `json:"routeNotAdmittedFor,omitempty"`
+}
+
+// RolloutSchedule describes when operand rollouts may happen.
+type RolloutSchedule struct {
+	// timeZone is the IANA time zone name, such as Europe/Berlin, in which
+	// the window schedules are evaluated. Defaults to UTC.
+	// +kubebuilder:validation:Optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// windows are the maintenance windows during which rollouts may happen.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Windows []MaintenanceWindow `json:"windows"`
+}
+
+// MaintenanceWindow is a recurring period during which rollouts may happen.
+type MaintenanceWindow struct {
+	// schedule is a cron expression with five fields (minute, hour, day of
+	// month, month, day of week) for when the window opens, such as
+	// "0 22 * * 1-5". Descriptors such as @daily are also accepted.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// duration is how long the window stays open, such as 2h.
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+}
+
+// SignatureType is the format of a graph-data image signature.
+// +kubebuilder:validation:Enum=Cosign;SimpleSigning
+type SignatureType string
+
+const (
+	// SignatureTypeCosign verifies key-based sigstore/cosign signatures, stored
+	// in the image's repository under the sha256-<digest>.sig tag. The signed
+	// payload is a simple-signing document which must reference the digest
+	// being verified.
+	SignatureTypeCosign SignatureType = "Cosign"
+
+	// SignatureTypeSimpleSigning verifies GPG simple-signing signatures, as
+	// created by podman push --sign-by or skopeo copy --sign-by. They are read
+	// from the signatureStore, or else from the registry signature API. The
+	// signed payload must reference the digest being verified and the
+	// graph-data image repository.
+	SignatureTypeSimpleSigning SignatureType = "SimpleSigning"
+)
+
+// GraphDataImageVerification describes how graph-data image signatures are verified.
+type GraphDataImageVerification struct {
+	// type is the signature format to verify.
+	// +kubebuilder:validation:Required
+	Type SignatureType `json:"type"`
+
+	// publicKey references the key which must have signed the graph-data
+	// image: a PEM-encoded public key for Cosign, or a GPG public keyring,
+	// ASCII-armored or binary, for SimpleSigning. The ConfigMap must be in the
+	// UpdateService's namespace.
+	// +kubebuilder:validation:Required
+	PublicKey ConfigMapKeyReference `json:"publicKey"`
+
+	// signatureStore is the https URL of a lookaside store serving the
+	// SimpleSigning signatures, such as the sigstore of a registries.d
+	// configuration. When unset, the signatures are read from the signature
+	// API of the registry. It is ignored for Cosign.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^https://`
+	SignatureStore string `json:"signatureStore,omitempty"`
+}
+
+// ConfigMapKeyReference references a key in a ConfigMap in the UpdateService's namespace.
+type ConfigMapKeyReference struct {
+	// name is the name of the ConfigMap.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// key is the key within the ConfigMap.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
 }
 
 // UpdateServiceStatus defines the observed state of UpdateService.
@@ -47,6 +311,166 @@ type UpdateServiceStatus struct {
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	PolicyEngineURI string `json:"policyEngineURI,optional"`
+
+	// graphDataHistory lists the most recently resolved graph-data image
+	// digests, newest first.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	GraphDataHistory []GraphDataRevision `json:"graphDataHistory,omitempty"`
+
+	// graphServing summarizes the update graphs the policy engine served for
+	// spec.expectedChannels.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	GraphServing *GraphServingStatus `json:"graphServing,omitempty"`
+
+	// graphBuilder summarizes graph-builder's scraping of the release
+	// repository, as reported by its status and metrics endpoints.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	GraphBuilder *GraphBuilderStatus `json:"graphBuilder,omitempty"`
+
+	// resourceErrors lists the resources which the last reconcile failed to
+	// ensure. The other resources were still reconciled.
+	// +listType=map
+	// +listMapKey=resource
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ResourceErrors []ResourceError `json:"resourceErrors,omitempty"`
+
+	// observedGeneration is the generation of the UpdateService which the
+	// last reconcile observed.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// lastAppliedHash is a hash of the resources which the last successful
+	// reconcile applied, and of their inputs such as the pull secret, the CAs
+	// and the graph-data image digest. While neither it nor the generation
+	// change, and the resources are not changed by others, the resources are
+	// not applied again.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	LastAppliedHash string `json:"lastAppliedHash,omitempty"`
+
+	// schemaVersion is the version of the operator's resource schema which
+	// the resources of the UpdateService were migrated to. When an upgraded
+	// operator has a later schema version, it migrates the resources before
+	// applying them, and reports the progress with the MigrationsCompleted
+	// condition.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SchemaVersion int32 `json:"schemaVersion,omitempty"`
+}
+
+// ResourceError describes why a resource could not be reconciled.
+type ResourceError struct {
+	// resource is the reconcile step which failed, named after the
+	// resource it ensures, e.g. NetworkPolicy or PolicyEngineRoute.
+	// +kubebuilder:validation:Required
+	Resource string `json:"resource"`
+
+	// reason is a machine readable reason for the failure.
+	// +kubebuilder:validation:Optional
+	Reason string `json:"reason,omitempty"`
+
+	// message is the error returned for the resource.
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+}
+
+// GraphBuilderStatus summarizes graph-builder's status and metrics endpoints.
+type GraphBuilderStatus struct {
+	// lastCheckTime is when the graph-builder endpoints were last read.
+	// +kubebuilder:validation:Required
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+
+	// observedDeploymentGeneration is the generation of the Deployment
+	// whose pods were read.
+	// +kubebuilder:validation:Optional
+	ObservedDeploymentGeneration int64 `json:"observedDeploymentGeneration,omitempty"`
+
+	// ready is whether graph-builder reported that it has built a graph.
+	// +kubebuilder:validation:Optional
+	Ready bool `json:"ready"`
+
+	// releases is the number of releases in the graph, after processing.
+	// +kubebuilder:validation:Optional
+	Releases int64 `json:"releases"`
+
+	// lastSuccessfulScrapeTime is when graph-builder last scraped the
+	// release repository successfully.
+	// +kubebuilder:validation:Optional
+	LastSuccessfulScrapeTime *metav1.Time `json:"lastSuccessfulScrapeTime,omitempty"`
+
+	// startTime is when the graph-builder process which was read started.
+	// +kubebuilder:validation:Optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// scrapeErrors is the number of failed scrapes since graph-builder
+	// started.
+	// +kubebuilder:validation:Optional
+	ScrapeErrors int64 `json:"scrapeErrors"`
+
+	// error is set when the graph-builder endpoints could not be read.
+	// +kubebuilder:validation:Optional
+	Error string `json:"error,omitempty"`
+}
+
+// GraphServingStatus is the result of querying the policy engine.
+type GraphServingStatus struct {
+	// lastCheckTime is when the policy engine was last queried.
+	// +kubebuilder:validation:Required
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+
+	// observedDeploymentGeneration is the generation of the Deployment
+	// whose pods were queried.
+	// +kubebuilder:validation:Optional
+	ObservedDeploymentGeneration int64 `json:"observedDeploymentGeneration,omitempty"`
+
+	// channels are the graphs served for each expected channel.
+	// +kubebuilder:validation:Optional
+	Channels []ChannelGraph `json:"channels,omitempty"`
+}
+
+// ChannelGraph summarizes the update graph served for a channel.
+type ChannelGraph struct {
+	// name is the name of the channel.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// nodes is the number of releases in the channel's graph.
+	// +kubebuilder:validation:Optional
+	Nodes int32 `json:"nodes"`
+
+	// edges is the number of update edges, including conditional edges, in
+	// the channel's graph.
+	// +kubebuilder:validation:Optional
+	Edges int32 `json:"edges"`
+
+	// error is set when the channel's graph could not be retrieved.
+	// +kubebuilder:validation:Optional
+	Error string `json:"error,omitempty"`
+}
+
+// GraphDataRevision is a graph-data image digest resolved by the operator.
+type GraphDataRevision struct {
+	// image is the by-digest graph-data image pull spec.
+	// +kubebuilder:validation:Required
+	Image string `json:"image"`
+
+	// firstSeen is when the operator first resolved this digest.
+	// +kubebuilder:validation:Required
+	FirstSeen metav1.Time `json:"firstSeen"`
+
+	// available is true once a rollout of this digest became Available.
+	// +kubebuilder:validation:Optional
+	Available bool `json:"available,omitempty"`
+
+	// rolledBack is true when the rollout of this digest never became
+	// Available, and the operator rolled back to an earlier digest.
+	// +kubebuilder:validation:Optional
+	RolledBack bool `json:"rolledBack,omitempty"`
 }
 
 // Condition Types
@@ -59,6 +483,27 @@ const (
 	ConditionRegistryCACertFound conditionsv1.ConditionType = "RegistryCACertFound"
 
 	ConditionReconcileError conditionsv1.ConditionType = "ReconcileError"
+
+	// ConditionGraphDataSignatureVerified reports whether the graph-data image
+	// digest passed the configured signature verification.
+	ConditionGraphDataSignatureVerified conditionsv1.ConditionType = "GraphDataSignatureVerified"
+
+	// ConditionGraphDataRolledBack reports whether the Deployment is pinned to
+	// an earlier graph-data image digest than the latest resolved one, either
+	// by the GraphDataPinAnnotation or by an automatic rollback.
+	ConditionGraphDataRolledBack conditionsv1.ConditionType = "GraphDataRolledBack"
+
+	// ConditionRolloutPending reports whether changes to the Deployment are
+	// being held until the next maintenance window of the rollout schedule.
+	ConditionRolloutPending conditionsv1.ConditionType = "RolloutPending"
+
+	// ConditionGraphServing reports whether the policy engine serves a
+	// non-empty update graph for each of the expected channels.
+	ConditionGraphServing conditionsv1.ConditionType = "GraphServing"
+
+	// ConditionMigrationsCompleted reports whether the resources of the
+	// UpdateService were migrated to the operator's schema version.
+	ConditionMigrationsCompleted conditionsv1.ConditionType = "MigrationsCompleted"
 )
 
 // +operator-sdk:csv:customresourcedefinitions:resources={{Service,v1,policy-engine-service}}
@@ -67,6 +512,9 @@ const (
 // +kubebuilder:printcolumn:name="Policy Engine URI",type="string",JSONPath=".status.policyEngineURI",description="The external URI which exposes the policy engine.",priority=1
 // +kubebuilder:printcolumn:name="Releases",type="string",JSONPath=".spec.releases",description="The repository in which release images are tagged.",priority=1
 // +kubebuilder:printcolumn:name="Graph Data Image",type="string",JSONPath=".spec.graphDataImage",description="The container image that contains the UpdateService graph data.",priority=1
+// +kubebuilder:printcolumn:name="Graph Releases",type="integer",JSONPath=".status.graphBuilder.releases",description="The number of releases in the graph built by graph-builder.",priority=1
+// +kubebuilder:printcolumn:name="Last Scrape",type="date",JSONPath=".status.graphBuilder.lastSuccessfulScrapeTime",description="When graph-builder last scraped the release repository successfully.",priority=1
+// +kubebuilder:printcolumn:name="Scrape Errors",type="integer",JSONPath=".status.graphBuilder.scrapeErrors",description="The number of failed scrapes since graph-builder started.",priority=1
 // +kubebuilder:printcolumn:name="Reconcile Completed",type="string",JSONPath=`.status.conditions[?(@.type=="ReconcileCompleted")].status`,description="Status reports whether all required resources have been created in the cluster and reflect the specified state.",priority=1
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=updateservices,scope=Namespaced
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UpdateServiceOperatorConfigSpec defines the defaults of the UpdateServices,
+// which their specs override, and the settings of the operator. Changes take
+// effect without restarting the operator.
+type UpdateServiceOperatorConfigSpec struct {
+	// operandImage is the default Cincinnati image of the graph-builder and
+	// policy engine containers. Defaults to the operand image of the
+	// operator.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	OperandImage string `json:"operandImage,omitempty"`
+
+	// resources are the default compute resources of the graph-builder and
+	// policy engine containers.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Resources *OperandResources `json:"resources,omitempty"`
+
+	// exposure is the default exposure of the policy engines. Defaults to
+	// Route.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Exposure ExposureMode `json:"exposure,omitempty"`
+
+	// resyncPeriod is how often each UpdateService is reconciled when
+	// nothing changes. Defaults to 5m.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XValidation:rule="duration(self) >= duration('30s')",message="resyncPeriod must be at least 30s"
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ResyncPeriod *metav1.Duration `json:"resyncPeriod,omitempty"`
+
+	// maxConcurrentReconciles is the number of UpdateServices which are
+	// reconciled concurrently. Defaults to 1.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	MaxConcurrentReconciles int32 `json:"maxConcurrentReconciles,omitempty"`
+
+	// logLevel is the log level of the operator: debug, info, error, or a
+	// verbosity n which enables V(n) logs. The logLevel key of the
+	// updateservice-operator-config ConfigMap takes priority over it.
+	// Defaults to the level of the command line.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^(debug|info|error|[0-9]+)$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	LogLevel string `json:"logLevel,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the UpdateServiceOperatorConfig resource."
+// +kubebuilder:resource:path=updateserviceoperatorconfigs,scope=Cluster
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'cluster'",message="the UpdateServiceOperatorConfig must be named cluster"
+
+// UpdateServiceOperatorConfig configures the UpdateService operator. It is a
+// singleton named cluster.
+type UpdateServiceOperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is standard object metadata.  More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// spec is the configuration of the operator.
+	// +kubebuilder:validation:Optional
+	Spec UpdateServiceOperatorConfigSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UpdateServiceOperatorConfigList contains a list of UpdateServiceOperatorConfig.
+type UpdateServiceOperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UpdateServiceOperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UpdateServiceOperatorConfig{}, &UpdateServiceOperatorConfigList{})
+}
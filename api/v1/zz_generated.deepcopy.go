@@ -6,15 +6,245 @@ package v1
 
 import (
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertThresholds) DeepCopyInto(out *AlertThresholds) {
+	*out = *in
+	if in.PolicyEngineUnavailableFor != nil {
+		in, out := &in.PolicyEngineUnavailableFor, &out.PolicyEngineUnavailableFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.GraphDataMaxAge != nil {
+		in, out := &in.GraphDataMaxAge, &out.GraphDataMaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RouteNotAdmittedFor != nil {
+		in, out := &in.RouteNotAdmittedFor, &out.RouteNotAdmittedFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertThresholds.
+func (in *AlertThresholds) DeepCopy() *AlertThresholds {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertThresholds)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChannelGraph) DeepCopyInto(out *ChannelGraph) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChannelGraph.
+func (in *ChannelGraph) DeepCopy() *ChannelGraph {
+	if in == nil {
+		return nil
+	}
+	out := new(ChannelGraph)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GraphBuilderStatus) DeepCopyInto(out *GraphBuilderStatus) {
+	*out = *in
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	if in.LastSuccessfulScrapeTime != nil {
+		in, out := &in.LastSuccessfulScrapeTime, &out.LastSuccessfulScrapeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GraphBuilderStatus.
+func (in *GraphBuilderStatus) DeepCopy() *GraphBuilderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GraphBuilderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GraphDataImageVerification) DeepCopyInto(out *GraphDataImageVerification) {
+	*out = *in
+	out.PublicKey = in.PublicKey
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GraphDataImageVerification.
+func (in *GraphDataImageVerification) DeepCopy() *GraphDataImageVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(GraphDataImageVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GraphDataRevision) DeepCopyInto(out *GraphDataRevision) {
+	*out = *in
+	in.FirstSeen.DeepCopyInto(&out.FirstSeen)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GraphDataRevision.
+func (in *GraphDataRevision) DeepCopy() *GraphDataRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(GraphDataRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GraphServingStatus) DeepCopyInto(out *GraphServingStatus) {
+	*out = *in
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	if in.Channels != nil {
+		in, out := &in.Channels, &out.Channels
+		*out = make([]ChannelGraph, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GraphServingStatus.
+func (in *GraphServingStatus) DeepCopy() *GraphServingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GraphServingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperandResources) DeepCopyInto(out *OperandResources) {
+	*out = *in
+	if in.GraphBuilder != nil {
+		in, out := &in.GraphBuilder, &out.GraphBuilder
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PolicyEngine != nil {
+		in, out := &in.PolicyEngine, &out.PolicyEngine
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperandResources.
+func (in *OperandResources) DeepCopy() *OperandResources {
+	if in == nil {
+		return nil
+	}
+	out := new(OperandResources)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyConfig) DeepCopyInto(out *ProxyConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyConfig.
+func (in *ProxyConfig) DeepCopy() *ProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceError) DeepCopyInto(out *ResourceError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceError.
+func (in *ResourceError) DeepCopy() *ResourceError {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceError)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutSchedule) DeepCopyInto(out *RolloutSchedule) {
+	*out = *in
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutSchedule.
+func (in *RolloutSchedule) DeepCopy() *RolloutSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateService) DeepCopyInto(out *UpdateService) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -68,9 +298,127 @@ func (in *UpdateServiceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateServiceOperatorConfig) DeepCopyInto(out *UpdateServiceOperatorConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateServiceOperatorConfig.
+func (in *UpdateServiceOperatorConfig) DeepCopy() *UpdateServiceOperatorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateServiceOperatorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UpdateServiceOperatorConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateServiceOperatorConfigList) DeepCopyInto(out *UpdateServiceOperatorConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]UpdateServiceOperatorConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateServiceOperatorConfigList.
+func (in *UpdateServiceOperatorConfigList) DeepCopy() *UpdateServiceOperatorConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateServiceOperatorConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *UpdateServiceOperatorConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateServiceOperatorConfigSpec) DeepCopyInto(out *UpdateServiceOperatorConfigSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(OperandResources)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResyncPeriod != nil {
+		in, out := &in.ResyncPeriod, &out.ResyncPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateServiceOperatorConfigSpec.
+func (in *UpdateServiceOperatorConfigSpec) DeepCopy() *UpdateServiceOperatorConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateServiceOperatorConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpdateServiceSpec) DeepCopyInto(out *UpdateServiceSpec) {
 	*out = *in
+	if in.GraphDataImageVerification != nil {
+		in, out := &in.GraphDataImageVerification, &out.GraphDataImageVerification
+		*out = new(GraphDataImageVerification)
+		**out = **in
+	}
+	if in.RolloutSchedule != nil {
+		in, out := &in.RolloutSchedule, &out.RolloutSchedule
+		*out = new(RolloutSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExpectedChannels != nil {
+		in, out := &in.ExpectedChannels, &out.ExpectedChannels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ScrapeFailureThreshold != nil {
+		in, out := &in.ScrapeFailureThreshold, &out.ScrapeFailureThreshold
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Alerts != nil {
+		in, out := &in.Alerts, &out.Alerts
+		*out = new(AlertThresholds)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(OperandResources)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(ProxyConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateServiceSpec.
@@ -93,6 +441,28 @@ func (in *UpdateServiceStatus) DeepCopyInto(out *UpdateServiceStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.GraphDataHistory != nil {
+		in, out := &in.GraphDataHistory, &out.GraphDataHistory
+		*out = make([]GraphDataRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.GraphServing != nil {
+		in, out := &in.GraphServing, &out.GraphServing
+		*out = new(GraphServingStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GraphBuilder != nil {
+		in, out := &in.GraphBuilder, &out.GraphBuilder
+		*out = new(GraphBuilderStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResourceErrors != nil {
+		in, out := &in.ResourceErrors, &out.ResourceErrors
+		*out = make([]ResourceError, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateServiceStatus.
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// fieldManager owns the fields which the operator applies.
+	fieldManager = "updateservice-operator"
+
+	// legacyFieldManager owned the fields which earlier operator versions
+	// wrote with client-side updates. The operator takes them over in
+	// migrateFieldManager, so that they do not conflict with its applies.
+	legacyFieldManager = "update-service-operator"
+)
+
+// apply server-side applies obj, which holds exactly the fields the operator
+// manages, and records the change when the object was created or changed.
+// Fields which other managers set are left alone, unless obj sets them too:
+// the operator then takes them over, which reverts their drift.
+func (r *UpdateServiceReconciler) apply(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, obj client.Object) error {
+	found, err := r.findApplied(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := r.serverSideApply(ctx, obj); err != nil {
+		return err
+	}
+
+	action := "Updated"
+	if found == nil {
+		action = "Created"
+	} else if found.GetResourceVersion() == obj.GetResourceVersion() {
+		return nil
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	reqLogger.Info(action+" "+kind, "Kind", kind, "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+	r.resourceChanged(ctx, instance, obj, action)
+	return nil
+}
+
+// findApplied returns the current state of obj, or nil if it does not exist.
+func (r *UpdateServiceReconciler) findApplied(ctx context.Context, obj client.Object) (client.Object, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	// applied objects must carry their apiVersion and kind
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	o, err := r.Scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	found := o.(client.Object)
+	err = r.Client.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, found)
+	if apiErrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return found, err
+}
+
+// serverSideApply applies obj with the operator's field manager, forcing the
+// ownership of the fields which other managers changed. obj then holds the
+// applied object.
+func (r *UpdateServiceReconciler) serverSideApply(ctx context.Context, obj client.Object, opts ...client.PatchOption) error {
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	return r.Client.Patch(ctx, obj, client.Apply, append([]client.PatchOption{client.FieldOwner(fieldManager), client.ForceOwnership}, opts...)...)
+}
+
+// managedByOthers returns whether a field manager other than the operator's
+// owns the field of obj at path, such as "f:spec", "f:replicas".
+func managedByOthers(obj client.Object, path ...string) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == fieldManager || entry.Manager == legacyFieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok, _ := unstructured.NestedFieldNoCopy(fields, path...); ok {
+			return true
+		}
+	}
+	return false
+}
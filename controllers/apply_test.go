@@ -0,0 +1,369 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newFakeClientBuilder returns a fake client builder whose client emulates
+// server-side apply with fakeApply.
+func newFakeClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: fakeApply})
+}
+
+// fakeApply emulates server-side apply, which the fake client does not
+// support, by merging the applied fields into the existing object. Unlike a
+// real apply, it does not remove fields that are no longer applied, and it
+// does not track field managers.
+func fakeApply(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	dryRun := len(options.DryRun) > 0
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, existing)
+	if apiErrors.IsNotFound(err) {
+		if dryRun {
+			return nil
+		}
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+
+	current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+	if err != nil {
+		return err
+	}
+	applied, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	merged := mergeApplied(runtime.DeepCopyJSON(current), applied)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(merged, obj); err != nil {
+		return err
+	}
+	if dryRun || equality.Semantic.DeepEqual(merged, current) {
+		return nil
+	}
+	return c.Update(ctx, obj)
+}
+
+// mergeApplied merges the non-null fields of applied into current. Lists are
+// replaced.
+func mergeApplied(current, applied map[string]interface{}) map[string]interface{} {
+	for key, value := range applied {
+		switch value := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			if existing, ok := current[key].(map[string]interface{}); ok {
+				current[key] = mergeApplied(existing, value)
+			} else {
+				current[key] = value
+			}
+		default:
+			current[key] = value
+		}
+	}
+	return current
+}
+
+func TestApply(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	recorder := record.NewFakeRecorder(10)
+	r := newTestReconciler(updateservice)
+	r.Recorder = recorder
+	cm := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "applied", Namespace: updateservice.Namespace},
+			Data:       map[string]string{"key": "value"},
+		}
+	}
+
+	if err := r.apply(context.TODO(), log, updateservice, cm()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"Normal ConfigMapCreated Created ConfigMap bar/applied"}, drainEvents(recorder))
+
+	// another manager's label is left alone, and nothing is changed
+	found := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "applied", Namespace: updateservice.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Labels = map[string]string{"injected": "true"}
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.apply(context.TODO(), log, updateservice, cm()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, drainEvents(recorder))
+
+	changed := cm()
+	changed.Data["key"] = "changed"
+	if err := r.apply(context.TODO(), log, updateservice, changed); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"Normal ConfigMapUpdated Updated ConfigMap bar/applied"}, drainEvents(recorder))
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "applied", Namespace: updateservice.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", found.Labels["injected"])
+	assert.Equal(t, "changed", found.Data["key"])
+}
+
+// newFieldManagerClientBuilder returns a fake client builder whose client
+// tracks field managers and their conflicts with the server-side apply field
+// manager, applying with ssaApply. Unlike a cluster, it deduces the schema of
+// the objects, so every list is atomic.
+func newFieldManagerClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: ssaApply})
+}
+
+// newFieldManager returns a server-side apply field manager of gvk objects,
+// which operates on unstructured objects.
+func newFieldManager(gvk schema.GroupVersionKind) (*managedfields.FieldManager, error) {
+	return managedfields.NewDefaultFieldManager(managedfields.NewDeducedTypeConverter(),
+		unstructuredConvertor{}, unstructuredDefaulter{}, unstructuredCreater{}, gvk, gvk.GroupVersion(), "", nil)
+}
+
+type unstructuredConvertor struct{}
+
+func (unstructuredConvertor) Convert(in, out, context interface{}) error {
+	return errors.New("not implemented")
+}
+
+func (unstructuredConvertor) ConvertToVersion(in runtime.Object, _ runtime.GroupVersioner) (runtime.Object, error) {
+	return in, nil
+}
+
+func (unstructuredConvertor) ConvertFieldLabel(_ schema.GroupVersionKind, _, _ string) (string, string, error) {
+	return "", "", errors.New("not implemented")
+}
+
+type unstructuredDefaulter struct{}
+
+func (unstructuredDefaulter) Default(runtime.Object) {}
+
+type unstructuredCreater struct{}
+
+func (unstructuredCreater) New(gvk schema.GroupVersionKind) (runtime.Object, error) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+// toUnstructured converts obj to an unstructured gvk object.
+func toUnstructured(obj runtime.Object, gvk schema.GroupVersionKind) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+// ssaApply emulates server-side apply with a field manager, which records
+// the managed fields of obj and fails with a conflict when obj changes a
+// field of another manager without forcing its ownership.
+func ssaApply(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	fm, err := newFieldManager(gvk)
+	if err != nil {
+		return err
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err = c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	notFound := apiErrors.IsNotFound(err)
+	if err != nil && !notFound {
+		return err
+	}
+	live := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	live.SetGroupVersionKind(gvk)
+	if !notFound {
+		if live, err = toUnstructured(existing, gvk); err != nil {
+			return err
+		}
+	}
+	applied, err := toUnstructured(obj, gvk)
+	if err != nil {
+		return err
+	}
+	result, err := fm.Apply(live, applied, options.FieldManager, ptr.Deref(options.Force, false))
+	if err != nil {
+		return err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(result.(*unstructured.Unstructured).Object, obj); err != nil {
+		return err
+	}
+	if len(options.DryRun) > 0 {
+		return nil
+	}
+	if notFound {
+		return c.Create(ctx, obj)
+	}
+	return c.Update(ctx, obj)
+}
+
+// updateAs updates obj as manager, such as a user editing it by hand.
+func updateAs(ctx context.Context, c client.Client, obj client.Object, manager string) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	fm, err := newFieldManager(gvk)
+	if err != nil {
+		return err
+	}
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return err
+	}
+	live, err := toUnstructured(existing, gvk)
+	if err != nil {
+		return err
+	}
+	updated, err := toUnstructured(obj, gvk)
+	if err != nil {
+		return err
+	}
+	result, err := fm.Update(live, updated, manager)
+	if err != nil {
+		return err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(result.(*unstructured.Unstructured).Object, obj); err != nil {
+		return err
+	}
+	return c.Update(ctx, obj)
+}
+
+// managerOf returns the manager of the field of obj at path.
+func managerOf(obj client.Object, path ...string) string {
+	for _, entry := range obj.GetManagedFields() {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok, _ := unstructured.NestedFieldNoCopy(fields, path...); ok {
+			return entry.Manager
+		}
+	}
+	return ""
+}
+
+func TestApplyTakesOverDrift(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler(updateservice)
+	r.Client = newFieldManagerClientBuilder().WithRuntimeObjects(updateservice).Build()
+	cm := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "applied", Namespace: updateservice.Namespace},
+			Data:       map[string]string{"key": "value"},
+		}
+	}
+	key := types.NamespacedName{Name: "applied", Namespace: updateservice.Namespace}
+
+	if err := r.apply(context.TODO(), log, updateservice, cm()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a hand-made change moves the ownership of the field to its manager
+	found := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), key, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Data["key"] = "edited"
+	found.Data["added"] = "by hand"
+	if err := updateAs(context.TODO(), r.Client, found, "kubectl-edit"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), key, found); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "kubectl-edit", managerOf(found, "f:data", "f:key"))
+
+	// which an apply without forcing the ownership conflicts with
+	err := r.Client.Patch(context.TODO(), cm(), client.Apply, client.FieldOwner(fieldManager))
+	assert.True(t, apiErrors.IsConflict(err), "expected a conflict, got %v", err)
+
+	// the operator takes the field over and reverts the drift
+	if err := r.apply(context.TODO(), log, updateservice, cm()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), key, found); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{"key": "value", "added": "by hand"}, found.Data)
+	assert.Equal(t, fieldManager, managerOf(found, "f:data", "f:key"))
+	assert.Equal(t, "kubectl-edit", managerOf(found, "f:data", "f:added"))
+}
+
+func TestEnsureDeploymentLeavesScaledReplicas(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler(updateservice)
+	r.Client = newFieldManagerClientBuilder().WithRuntimeObjects(updateservice).Build()
+	ensure := func() *appsv1.Deployment {
+		t.Helper()
+		resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.ensureDeployment(context.TODO(), log, updateservice, resources, ""); err != nil {
+			t.Fatal(err)
+		}
+		deployment := &appsv1.Deployment{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, deployment); err != nil {
+			t.Fatal(err)
+		}
+		return deployment
+	}
+
+	deployment := ensure()
+	assert.Equal(t, fieldManager, managerOf(deployment, "f:spec", "f:replicas"))
+
+	// a HorizontalPodAutoscaler scales the Deployment
+	deployment.Spec.Replicas = ptr.To(int32(5))
+	if err := updateAs(context.TODO(), r.Client, deployment, "kube-controller-manager"); err != nil {
+		t.Fatal(err)
+	}
+
+	deployment = ensure()
+	assert.Equal(t, int32(5), *deployment.Spec.Replicas)
+	assert.Equal(t, "kube-controller-manager", managerOf(deployment, "f:spec", "f:replicas"))
+}
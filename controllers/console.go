@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	_ "embed"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	consolev1 "github.com/openshift/api/console/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// ConsoleDashboardNamespace is the namespace from which the console
+	// loads monitoring dashboards.
+	ConsoleDashboardNamespace = "openshift-config-managed"
+	// ConsoleDashboardLabel marks ConfigMaps holding console dashboards.
+	ConsoleDashboardLabel = "console.openshift.io/dashboard"
+)
+
+//go:embed assets/updateservice-dashboard.json
+var updateServiceDashboard string
+
+// consoleAvailable reports whether the cluster serves ConsoleLinks, which is
+// not the case when the console capability is disabled.
+func consoleAvailable(mapper meta.RESTMapper) (bool, error) {
+	_, err := mapper.RESTMapping(schema.GroupKind{Group: consolev1.GroupName, Kind: "ConsoleLink"}, consolev1.GroupVersion.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func newConsoleDashboard() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nameConsoleDashboard,
+			Namespace: ConsoleDashboardNamespace,
+			Annotations: map[string]string{
+				DescriptionAnnotation: "This ConfigMap holds the OpenShift Update Service console dashboard, " +
+					"which shows policy-engine requests, graph-builder scraping and pod restarts of each UpdateService.",
+			},
+			Labels: map[string]string{
+				ConsoleDashboardLabel: "true",
+			},
+		},
+		Data: map[string]string{
+			"openshift-update-service.json": updateServiceDashboard,
+		},
+	}
+}
+
+func newConsoleLink(instance *cv1.UpdateService) *consolev1.ConsoleLink {
+	return &consolev1.ConsoleLink{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nameConsoleLink(instance),
+			Annotations: map[string]string{
+				DescriptionAnnotation: "This ConsoleLink lists the UpdateService policy engine in the console application menu.",
+			},
+			Labels: map[string]string{
+				instanceNamespaceLabel: instance.Namespace,
+				instanceNameLabel:      instance.Name,
+			},
+		},
+		Spec: consolev1.ConsoleLinkSpec{
+			Link: consolev1.Link{
+				Text: instance.Namespace + "/" + instance.Name,
+				Href: instance.Status.PolicyEngineURI,
+			},
+			Location: consolev1.ApplicationMenu,
+			ApplicationMenu: &consolev1.ApplicationMenuSpec{
+				Section: "Update Services",
+			},
+		},
+	}
+}
+
+// ensureConsoleDashboard publishes the dashboard shared by all UpdateServices.
+func (r *UpdateServiceReconciler) ensureConsoleDashboard(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	available, err := consoleAvailable(r.Client.RESTMapper())
+	if err != nil {
+		r.handleErr(reqLogger, instance, "FindConsoleFailed", err)
+		return err
+	}
+	if !available {
+		return nil
+	}
+
+	if err := r.ensureConfigMap(ctx, reqLogger, instance, newConsoleDashboard()); err != nil {
+		if apiErrors.IsForbidden(err) {
+			// OLM only grants permissions in the target namespaces, the
+			// dashboard is published once openshift-config-managed is granted
+			reqLogger.Info("Not allowed to publish the console dashboard", "Namespace", ConsoleDashboardNamespace, "Error", err.Error())
+			return nil
+		}
+		r.handleErr(reqLogger, instance, "EnsureConsoleDashboardFailed", err)
+		return err
+	}
+	return nil
+}
+
+// ensureConsoleLink links the console application menu to the policy engine
+// once its Route is admitted. Policy engines exposed inside of the cluster only
+// are not linked.
+func (r *UpdateServiceReconciler) ensureConsoleLink(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	available, err := consoleAvailable(r.Client.RESTMapper())
+	if err != nil {
+		r.handleErr(reqLogger, instance, "FindConsoleFailed", err)
+		return err
+	}
+	if !available {
+		return nil
+	}
+
+	link := newConsoleLink(instance)
+	found := &consolev1.ConsoleLink{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: link.Name}, found)
+	if err != nil && !apiErrors.IsNotFound(err) {
+		r.handleErr(reqLogger, instance, "GetConsoleLinkFailed", err)
+		return err
+	}
+	exists := err == nil
+
+	switch {
+	case resources.policyEngineRoute == nil || instance.Status.PolicyEngineURI == "":
+		if exists {
+			reqLogger.Info("Deleting ConsoleLink", "Kind", "ConsoleLink", "Name", link.Name)
+			if err := r.delete(ctx, instance, found); err != nil && !apiErrors.IsNotFound(err) {
+				r.handleErr(reqLogger, instance, "DeleteConsoleLinkFailed", err)
+				return err
+			}
+		}
+	case !exists:
+		reqLogger.Info("Creating ConsoleLink", "Kind", "ConsoleLink", "Name", link.Name)
+		if err := r.create(ctx, instance, link); err != nil {
+			r.handleErr(reqLogger, instance, "CreateConsoleLinkFailed", err)
+			return err
+		}
+	case !reflect.DeepEqual(found.Spec, link.Spec):
+		reqLogger.Info("Updating ConsoleLink", "Kind", "ConsoleLink", "Name", link.Name)
+		updated := found.DeepCopy()
+		updated.Spec = link.Spec
+		if err := r.update(ctx, instance, updated); err != nil {
+			r.handleErr(reqLogger, instance, "UpdateConsoleLinkFailed", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteConsoleLinks deletes the ConsoleLinks of a deleted UpdateService.
+func (r *UpdateServiceReconciler) deleteConsoleLinks(ctx context.Context, name types.NamespacedName) error {
+	available, err := consoleAvailable(r.Client.RESTMapper())
+	if err != nil || !available {
+		return err
+	}
+	return r.Client.DeleteAllOf(ctx, &consolev1.ConsoleLink{}, client.MatchingLabels{
+		instanceNamespaceLabel: name.Namespace,
+		instanceNameLabel:      name.Name,
+	})
+}
+
+// deleteConsoleDashboard deletes the console dashboard once no UpdateService
+// is left in the watched namespaces.
+func (r *UpdateServiceReconciler) deleteConsoleDashboard(ctx context.Context, reqLogger logr.Logger) error {
+	available, err := consoleAvailable(r.Client.RESTMapper())
+	if err != nil || !available {
+		return err
+	}
+	updateservices, err := listUpdateServices(ctx, r.Client, r.watchedNamespaces())
+	if err != nil || len(updateservices) > 0 {
+		return err
+	}
+	reqLogger.Info("Deleting the console dashboard", "Kind", "ConfigMap", "Namespace", ConsoleDashboardNamespace, "Name", nameConsoleDashboard)
+	err = r.Client.Delete(ctx, newConsoleDashboard())
+	if apiErrors.IsNotFound(err) || apiErrors.IsForbidden(err) {
+		return nil
+	}
+	return err
+}
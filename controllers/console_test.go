@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	consolev1 "github.com/openshift/api/console/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func newConsoleTestReconciler(updateservice *cv1.UpdateService) *UpdateServiceReconciler {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(consolev1.GroupVersion.WithKind("ConsoleLink"), meta.RESTScopeRoot)
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRESTMapper(mapper).
+		WithRuntimeObjects(updateservice).WithStatusSubresource(&cv1.UpdateService{}).Build()
+	return r
+}
+
+func TestEnsureConsoleDashboard(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newConsoleTestReconciler(updateservice)
+
+	if err := r.ensureConsoleDashboard(context.TODO(), log, updateservice, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	found := &corev1.ConfigMap{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameConsoleDashboard, Namespace: ConsoleDashboardNamespace}, found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", found.Labels[ConsoleDashboardLabel])
+	var dashboard map[string]interface{}
+	if assert.NoError(t, json.Unmarshal([]byte(found.Data["openshift-update-service.json"]), &dashboard)) {
+		assert.Equal(t, "OpenShift Update Service", dashboard["title"])
+	}
+}
+
+func TestEnsureConsoleDashboardForbidden(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newConsoleTestReconciler(updateservice)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return apiErrors.NewForbidden(corev1.Resource("configmaps"), obj.GetName(), errors.New("denied"))
+		},
+	})
+
+	// installed by OLM without a grant in openshift-config-managed
+	assert.NoError(t, r.ensureConsoleDashboard(context.TODO(), log, updateservice, nil))
+	assert.Empty(t, updateservice.Status.Conditions)
+}
+
+func TestDeleteConsoleDashboard(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newConsoleTestReconciler(updateservice)
+	name := types.NamespacedName{Name: nameConsoleDashboard, Namespace: ConsoleDashboardNamespace}
+	if err := r.ensureConsoleDashboard(context.TODO(), log, updateservice, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the dashboard is kept while an UpdateService is left
+	if err := r.deleteConsoleDashboard(context.TODO(), log); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, r.Client.Get(context.TODO(), name, &corev1.ConfigMap{}))
+
+	if err := r.Client.Delete(context.TODO(), updateservice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Client.Get(context.TODO(), name, &corev1.ConfigMap{})
+	assert.True(t, apiErrors.IsNotFound(err), "expected no dashboard, got %v", err)
+
+	// already deleted
+	assert.NoError(t, r.deleteConsoleDashboard(context.TODO(), log))
+}
+
+func TestEnsureConsoleLink(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newConsoleTestReconciler(updateservice)
+	name := types.NamespacedName{Name: nameConsoleLink(updateservice)}
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no link until the Route is admitted
+	if err := r.ensureConsoleLink(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	err = r.Client.Get(context.TODO(), name, &consolev1.ConsoleLink{})
+	assert.True(t, apiErrors.IsNotFound(err), "expected no ConsoleLink, got %v", err)
+
+	updateservice.Status.PolicyEngineURI = "https://foo-route-bar.apps.example.com"
+	if err := r.ensureConsoleLink(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	link := &consolev1.ConsoleLink{}
+	if err := r.Client.Get(context.TODO(), name, link); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "updateservice-bar-foo", link.Name)
+	assert.Equal(t, "https://foo-route-bar.apps.example.com", link.Spec.Href)
+	assert.Equal(t, consolev1.ApplicationMenu, link.Spec.Location)
+
+	updateservice.Status.PolicyEngineURI = "https://updates.example.com"
+	if err := r.ensureConsoleLink(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), name, link); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://updates.example.com", link.Spec.Href)
+
+	// the policy engine is not internalOnly outside of the cluster anymore
+	internalOnly := updateservice.DeepCopy()
+	internalOnly.Spec.Exposure = cv1.ExposureNone
+	internal, err := newKubeResources(internalOnly, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ensureConsoleLink(context.TODO(), log, updateservice, internal); err != nil {
+		t.Fatal(err)
+	}
+	err = r.Client.Get(context.TODO(), name, &consolev1.ConsoleLink{})
+	assert.True(t, apiErrors.IsNotFound(err), "expected no ConsoleLink, got %v", err)
+	if err := r.ensureConsoleLink(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	// the UpdateService was deleted
+	if err := r.deleteConsoleLinks(context.TODO(), types.NamespacedName{Namespace: updateservice.Namespace, Name: updateservice.Name}); err != nil {
+		t.Fatal(err)
+	}
+	err = r.Client.Get(context.TODO(), name, &consolev1.ConsoleLink{})
+	assert.True(t, apiErrors.IsNotFound(err), "expected no ConsoleLink, got %v", err)
+}
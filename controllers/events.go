@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// eventRepeatInterval is how long an identical event is suppressed
+	// after it was recorded. The periodic requeue would otherwise repeat
+	// persistent failures every five minutes.
+	eventRepeatInterval = 30 * time.Minute
+
+	// maxTrackedEvents bounds the memory used to suppress repeated events.
+	maxTrackedEvents = 1024
+)
+
+type eventKey struct {
+	uid       types.UID
+	eventType string
+	reason    string
+	message   string
+}
+
+// dedupingRecorder is an EventRecorder that drops events identical to one
+// recorded for the same object within the repeat interval.
+type dedupingRecorder struct {
+	record.EventRecorder
+
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	recorded map[eventKey]time.Time
+}
+
+func newDedupingRecorder(recorder record.EventRecorder, interval time.Duration) *dedupingRecorder {
+	return &dedupingRecorder{
+		EventRecorder: recorder,
+		interval:      interval,
+		now:           time.Now,
+		recorded:      map[eventKey]time.Time{},
+	}
+}
+
+func (d *dedupingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if d.repeated(object, eventtype, reason, message) {
+		return
+	}
+	d.EventRecorder.Event(object, eventtype, reason, message)
+}
+
+func (d *dedupingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	d.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (d *dedupingRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	message := fmt.Sprintf(messageFmt, args...)
+	if d.repeated(object, eventtype, reason, message) {
+		return
+	}
+	d.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", message)
+}
+
+// repeated reports whether an identical event was recorded within the
+// repeat interval, and otherwise remembers this one.
+func (d *dedupingRecorder) repeated(object runtime.Object, eventtype, reason, message string) bool {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return false
+	}
+	key := eventKey{uid: accessor.GetUID(), eventType: eventtype, reason: reason, message: message}
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.recorded[key]; ok && now.Sub(last) < d.interval {
+		return true
+	}
+	if len(d.recorded) >= maxTrackedEvents {
+		for k, last := range d.recorded {
+			if now.Sub(last) >= d.interval {
+				delete(d.recorded, k)
+			}
+		}
+	}
+	if len(d.recorded) < maxTrackedEvents {
+		d.recorded[key] = now
+	}
+	return false
+}
+
+// event records an event on the UpdateService, if a recorder is configured.
+func (r *UpdateServiceReconciler) event(instance *cv1.UpdateService, eventtype, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(instance, eventtype, eventReason(reason), message)
+}
+
+// create creates obj and records a <Kind>Created event on the UpdateService.
+func (r *UpdateServiceReconciler) create(ctx context.Context, instance *cv1.UpdateService, obj client.Object) error {
+	if err := r.Client.Create(ctx, obj); err != nil {
+		return err
+	}
+	r.resourceChanged(ctx, instance, obj, "Created")
+	return nil
+}
+
+// update updates obj and records a <Kind>Updated event on the UpdateService.
+func (r *UpdateServiceReconciler) update(ctx context.Context, instance *cv1.UpdateService, obj client.Object) error {
+	if err := r.Client.Update(ctx, obj); err != nil {
+		return err
+	}
+	r.resourceChanged(ctx, instance, obj, "Updated")
+	return nil
+}
+
+// delete deletes obj and records a <Kind>Deleted event on the UpdateService.
+func (r *UpdateServiceReconciler) delete(ctx context.Context, instance *cv1.UpdateService, obj client.Object) error {
+	if err := r.Client.Delete(ctx, obj); err != nil {
+		return err
+	}
+	r.resourceChanged(ctx, instance, obj, "Deleted")
+	return nil
+}
+
+// resourceChanged records an event, counts and traces the change of obj.
+func (r *UpdateServiceReconciler) resourceChanged(ctx context.Context, instance *cv1.UpdateService, obj client.Object, action string) {
+	kind := "Resource"
+	if gvk, err := apiutil.GVKForObject(obj, r.Scheme); err == nil {
+		kind = gvk.Kind
+	}
+	observeResourceChange(instance, kind, action)
+	traceResourceChange(ctx, kind, obj, action)
+	r.event(instance, corev1.EventTypeNormal, kind+action,
+		fmt.Sprintf("%s %s %s/%s", action, kind, obj.GetNamespace(), obj.GetName()))
+}
+
+// recordConditionEvents records an event for each condition whose status or
+// reason differs from the previous conditions. Failed reconciles are already
+// recorded by handleErr.
+func (r *UpdateServiceReconciler) recordConditionEvents(instance *cv1.UpdateService, previous []conditionsv1.Condition) {
+	for _, condition := range instance.Status.Conditions {
+		old := conditionsv1.FindStatusCondition(previous, condition.Type)
+		if old != nil && old.Status == condition.Status && old.Reason == condition.Reason {
+			continue
+		}
+		if condition.Type == cv1.ConditionReconcileCompleted && condition.Status == corev1.ConditionFalse {
+			continue
+		}
+		eventtype := corev1.EventTypeNormal
+		if conditionIsBad(condition) {
+			eventtype = corev1.EventTypeWarning
+		}
+		message := fmt.Sprintf("%s is %s", condition.Type, condition.Status)
+		if condition.Message != "" {
+			message += ": " + condition.Message
+		}
+		r.event(instance, eventtype, string(condition.Type)+eventReason(condition.Reason), message)
+	}
+}
+
+// conditionIsBad reports whether a condition describes a problem that an
+// administrator should look at.
+func conditionIsBad(condition conditionsv1.Condition) bool {
+	switch condition.Type {
+	case cv1.ConditionReconcileError, cv1.ConditionGraphDataRolledBack, conditionsv1.ConditionDegraded:
+		return condition.Status == corev1.ConditionTrue
+	case cv1.ConditionReconcileCompleted, cv1.ConditionGraphDataSignatureVerified, cv1.ConditionGraphServing:
+		return condition.Status == corev1.ConditionFalse
+	case cv1.ConditionRegistryCACertFound:
+		return condition.Status == corev1.ConditionFalse && condition.Reason != "NotConfigured"
+	}
+	return false
+}
+
+// eventReason turns a condition or error reason into the CamelCase form
+// expected of event reasons, for example "Unable to create UpdateService
+// route" into "UnableToCreateUpdateServiceRoute".
+func eventReason(reason string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(reason, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// drainEvents returns the events recorded so far.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestDedupingRecorder(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	fake := record.NewFakeRecorder(10)
+	recorder := newDedupingRecorder(fake, 30*time.Minute)
+	recorder.now = func() time.Time { return now }
+
+	updateservice := newDefaultUpdateService()
+	updateservice.UID = types.UID("first")
+	other := newDefaultUpdateService()
+	other.UID = types.UID("second")
+
+	recorder.Event(updateservice, corev1.EventTypeWarning, "CreateDeploymentFailed", "boom")
+	recorder.Event(updateservice, corev1.EventTypeWarning, "CreateDeploymentFailed", "boom")
+	recorder.Event(other, corev1.EventTypeWarning, "CreateDeploymentFailed", "boom")
+	recorder.Eventf(updateservice, corev1.EventTypeWarning, "CreateDeploymentFailed", "boom %d", 2)
+	assert.Equal(t, []string{
+		"Warning CreateDeploymentFailed boom",
+		"Warning CreateDeploymentFailed boom",
+		"Warning CreateDeploymentFailed boom 2",
+	}, drainEvents(fake))
+
+	now = now.Add(31 * time.Minute)
+	recorder.Event(updateservice, corev1.EventTypeWarning, "CreateDeploymentFailed", "boom")
+	assert.Equal(t, []string{"Warning CreateDeploymentFailed boom"}, drainEvents(fake))
+}
+
+func TestResourceEvents(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(updateservice)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	if err := r.ensureGraphBuilderService(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	resources.graphBuilderService.Spec.Ports[0].Port++
+	if err := r.ensureGraphBuilderService(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	// an unchanged Service records nothing
+	if err := r.ensureGraphBuilderService(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	name := fmt.Sprintf("%s/%s", updateservice.Namespace, nameGraphBuilderService(updateservice))
+	assert.Equal(t, []string{
+		"Normal ServiceCreated Created Service " + name,
+		"Normal ServiceUpdated Updated Service " + name,
+	}, drainEvents(recorder))
+
+	r.handleErr(log, updateservice, "Unable to create UpdateService route", fmt.Errorf("boom"))
+	assert.Equal(t, []string{"Warning UnableToCreateUpdateServiceRoute boom"}, drainEvents(recorder))
+}
+
+func TestRecordConditionEvents(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	previous := []conditionsv1.Condition{
+		{Type: cv1.ConditionReconcileCompleted, Status: corev1.ConditionTrue, Reason: "Success"},
+		{Type: cv1.ConditionRegistryCACertFound, Status: corev1.ConditionFalse, Reason: "NotConfigured"},
+		{Type: cv1.ConditionGraphServing, Status: corev1.ConditionTrue, Reason: "GraphServed"},
+	}
+	updateservice.Status.Conditions = []conditionsv1.Condition{
+		{Type: cv1.ConditionReconcileCompleted, Status: corev1.ConditionTrue, Reason: "Success"},
+		{Type: cv1.ConditionRegistryCACertFound, Status: corev1.ConditionFalse, Reason: "NotConfigured"},
+		{Type: cv1.ConditionGraphServing, Status: corev1.ConditionFalse, Reason: "ChannelsMissing", Message: "no releases for stable-4.99"},
+		{Type: conditionsv1.ConditionDegraded, Status: corev1.ConditionFalse, Reason: "AsExpected"},
+	}
+	r := newTestReconciler(updateservice)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	r.recordConditionEvents(updateservice, previous)
+	assert.Equal(t, []string{
+		"Warning GraphServingChannelsMissing GraphServing is False: no releases for stable-4.99",
+		"Normal DegradedAsExpected Degraded is False",
+	}, drainEvents(recorder))
+
+	// failed reconciles are recorded by handleErr
+	updateservice.Status.Conditions = []conditionsv1.Condition{
+		{Type: cv1.ConditionReconcileCompleted, Status: corev1.ConditionFalse, Reason: "CreateDeploymentFailed"},
+	}
+	r.recordConditionEvents(updateservice, previous)
+	assert.Empty(t, drainEvents(recorder))
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// defaultScrapeFailureThreshold is how long graph-builder scraping may fail
+// before the UpdateService is Degraded, when spec.scrapeFailureThreshold is
+// unset. graph-builder scrapes every five minutes.
+const defaultScrapeFailureThreshold = 30 * time.Minute
+
+// graph-builder metrics, see
+// https://github.com/openshift/cincinnati/blob/master/graph-builder/src/graph.rs
+const (
+	metricGraphBuilderReleases     = "cincinnati_gb_graph_final_releases"
+	metricGraphBuilderLastRefresh  = "cincinnati_gb_graph_last_successful_refresh_timestamp"
+	metricGraphBuilderScrapeErrors = "cincinnati_gb_graph_upstream_errors_total"
+	metricProcessStartTime         = "process_start_time_seconds"
+)
+
+// readGraphBuilderStatus reads graph-builder's readiness and metrics from its
+// status port.
+func readGraphBuilderStatus(ctx context.Context, client *http.Client, baseURL string) (*cv1.GraphBuilderStatus, error) {
+	status := &cv1.GraphBuilderStatus{}
+
+	resp, err := operandGet(ctx, client, baseURL+"/readiness")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	status.Ready = resp.StatusCode == http.StatusOK
+
+	resp, err = operandGet(ctx, client, baseURL+"/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s/metrics: %s", baseURL, resp.Status)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(io.LimitReader(resp.Body, graphMaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("GET %s/metrics: %w", baseURL, err)
+	}
+
+	if value, ok := metricValue(families[metricGraphBuilderReleases]); ok {
+		status.Releases = int64(value)
+	}
+	if value, ok := metricValue(families[metricGraphBuilderScrapeErrors]); ok {
+		status.ScrapeErrors = int64(value)
+	}
+	if value, ok := metricValue(families[metricGraphBuilderLastRefresh]); ok && value > 0 {
+		status.LastSuccessfulScrapeTime = unixTime(value)
+	}
+	if value, ok := metricValue(families[metricProcessStartTime]); ok && value > 0 {
+		status.StartTime = unixTime(value)
+	}
+	return status, nil
+}
+
+func operandGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// metricValue returns the value of the first sample of a gauge, counter or
+// untyped metric family.
+func metricValue(family *dto.MetricFamily) (float64, bool) {
+	if family == nil || len(family.Metric) == 0 {
+		return 0, false
+	}
+	m := family.Metric[0]
+	switch {
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	}
+	return 0, false
+}
+
+func unixTime(seconds float64) *metav1.Time {
+	whole, fraction := math.Modf(seconds)
+	t := metav1.NewTime(time.Unix(int64(whole), int64(fraction*1e9)).UTC())
+	return &t
+}
+
+// ensureGraphBuilderStatus reads graph-builder's status and metrics endpoints
+// once the Deployment rollout completed, and periodically afterwards, and sets
+// the Degraded condition when scraping has failed for too long.
+func (r *UpdateServiceReconciler) ensureGraphBuilderStatus(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	deployment := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: resources.deployment.Name, Namespace: resources.deployment.Namespace}, deployment)
+	if err != nil && !apiErrors.IsNotFound(err) {
+		r.handleErr(reqLogger, instance, "GetDeploymentFailed", err)
+		return err
+	}
+
+	previous := instance.Status.GraphBuilder
+	if err == nil && operandRolledOut(deployment) &&
+		(previous == nil || r.operandCheckDue(previous.LastCheckTime, previous.ObservedDeploymentGeneration, deployment)) {
+		status, err := readGraphBuilderStatus(ctx, r.operandHTTPClient(), serviceURL(resources.graphBuilderService, "status-gb"))
+		if err != nil {
+			reqLogger.Info("Failed to read the graph-builder status", "Error", err.Error())
+			status = &cv1.GraphBuilderStatus{Error: err.Error()}
+			if previous != nil {
+				// keep the last known scrape summary
+				status.LastSuccessfulScrapeTime = previous.LastSuccessfulScrapeTime
+				status.StartTime = previous.StartTime
+			}
+		}
+		status.LastCheckTime = metav1.NewTime(r.now())
+		status.ObservedDeploymentGeneration = deployment.Generation
+		instance.Status.GraphBuilder = status
+	}
+
+	if instance.Status.GraphBuilder != nil {
+		threshold := defaultScrapeFailureThreshold
+		if instance.Spec.ScrapeFailureThreshold != nil {
+			threshold = instance.Spec.ScrapeFailureThreshold.Duration
+		}
+		conditionsv1.SetStatusCondition(&instance.Status.Conditions, graphBuilderDegradedCondition(instance.Status.GraphBuilder, threshold, r.now()))
+	}
+	return nil
+}
+
+// graphBuilderDegradedCondition derives the Degraded condition from the most
+// recent graph-builder status.
+func graphBuilderDegradedCondition(status *cv1.GraphBuilderStatus, threshold time.Duration, now time.Time) conditionsv1.Condition {
+	if status.Error != "" {
+		return conditionsv1.Condition{
+			Type:    conditionsv1.ConditionDegraded,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "GraphBuilderStatusUnavailable",
+			Message: fmt.Sprintf("Failed to read the graph-builder status: %s", status.Error),
+		}
+	}
+
+	since, format := status.LastSuccessfulScrapeTime, "graph-builder last scraped the release repository successfully %s ago"
+	if since == nil {
+		since, format = status.StartTime, "graph-builder has not scraped the release repository successfully since it started %s ago"
+	}
+	if since == nil {
+		return conditionsv1.Condition{
+			Type:    conditionsv1.ConditionDegraded,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "NoSuccessfulScrape",
+			Message: "graph-builder has not scraped the release repository successfully yet",
+		}
+	}
+	if failing := now.Sub(since.Time); failing > threshold {
+		return conditionsv1.Condition{
+			Type:    conditionsv1.ConditionDegraded,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ScrapeFailing",
+			Message: fmt.Sprintf(format+", which is longer than %s; %d scrapes failed", failing.Round(time.Second), threshold, status.ScrapeErrors),
+		}
+	}
+	return conditionsv1.Condition{
+		Type:    conditionsv1.ConditionDegraded,
+		Status:  corev1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: fmt.Sprintf("graph-builder serves %d releases", status.Releases),
+	}
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func newGraphBuilderServer(t *testing.T, ready bool, lastRefresh time.Time) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/readiness":
+			if !ready {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/metrics":
+			fmt.Fprintf(w, `# HELP cincinnati_gb_graph_final_releases Number of releases in the final graph, after processing
+# TYPE cincinnati_gb_graph_final_releases gauge
+cincinnati_gb_graph_final_releases 412
+# HELP cincinnati_gb_graph_last_successful_refresh_timestamp UTC timestamp of last successful graph refresh
+# TYPE cincinnati_gb_graph_last_successful_refresh_timestamp gauge
+cincinnati_gb_graph_last_successful_refresh_timestamp %d
+# HELP cincinnati_gb_graph_upstream_errors_total Total number of upstream scraping errors
+# TYPE cincinnati_gb_graph_upstream_errors_total counter
+cincinnati_gb_graph_upstream_errors_total 3
+# HELP process_start_time_seconds Start time of the process since unix epoch in seconds.
+# TYPE process_start_time_seconds gauge
+process_start_time_seconds 1.7149e+09
+`, lastRefresh.Unix())
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_readGraphBuilderStatus(t *testing.T) {
+	lastRefresh := time.Date(2024, 5, 6, 11, 55, 0, 0, time.UTC)
+	server := newGraphBuilderServer(t, true, lastRefresh)
+
+	status, err := readGraphBuilderStatus(context.TODO(), server.Client(), server.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, status.Ready)
+	assert.Equal(t, int64(412), status.Releases)
+	assert.Equal(t, int64(3), status.ScrapeErrors)
+	if assert.NotNil(t, status.LastSuccessfulScrapeTime) {
+		assert.True(t, lastRefresh.Equal(status.LastSuccessfulScrapeTime.Time))
+	}
+	if assert.NotNil(t, status.StartTime) {
+		assert.Equal(t, int64(1714900000), status.StartTime.Unix())
+	}
+}
+
+func Test_graphBuilderDegradedCondition(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+	for _, tc := range []struct {
+		name           string
+		status         cv1.GraphBuilderStatus
+		expectedStatus corev1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "recent scrape",
+			status:         cv1.GraphBuilderStatus{LastSuccessfulScrapeTime: at(5 * time.Minute), StartTime: at(time.Hour)},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "AsExpected",
+		},
+		{
+			name:           "scrape failing for longer than the threshold",
+			status:         cv1.GraphBuilderStatus{LastSuccessfulScrapeTime: at(45 * time.Minute), StartTime: at(time.Hour)},
+			expectedStatus: corev1.ConditionTrue,
+			expectedReason: "ScrapeFailing",
+		},
+		{
+			name:           "recently started",
+			status:         cv1.GraphBuilderStatus{StartTime: at(10 * time.Minute)},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "AsExpected",
+		},
+		{
+			name:           "never scraped since it started",
+			status:         cv1.GraphBuilderStatus{StartTime: at(time.Hour)},
+			expectedStatus: corev1.ConditionTrue,
+			expectedReason: "ScrapeFailing",
+		},
+		{
+			name:           "no timestamps",
+			status:         cv1.GraphBuilderStatus{},
+			expectedStatus: corev1.ConditionUnknown,
+			expectedReason: "NoSuccessfulScrape",
+		},
+		{
+			name:           "status unavailable",
+			status:         cv1.GraphBuilderStatus{Error: "connection refused"},
+			expectedStatus: corev1.ConditionUnknown,
+			expectedReason: "GraphBuilderStatusUnavailable",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			condition := graphBuilderDegradedCondition(&tc.status, 30*time.Minute, now)
+			assert.Equal(t, conditionsv1.ConditionDegraded, condition.Type)
+			assert.Equal(t, tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+		})
+	}
+}
+
+func TestEnsureGraphBuilderStatus(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	server := newGraphBuilderServer(t, true, now.Add(-time.Hour))
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateservice := newDefaultUpdateService()
+	updateservice.Spec.ScrapeFailureThreshold = &metav1.Duration{Duration: 2 * time.Hour}
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := resources.deployment.DeepCopy()
+	deployment.Status.Replicas = updateservice.Spec.Replicas
+	deployment.Status.UpdatedReplicas = updateservice.Spec.Replicas
+	deployment.Status.AvailableReplicas = updateservice.Spec.Replicas
+	r := newTestReconciler(updateservice, deployment)
+	transport := &redirectTransport{target: target}
+	r.operandHTTP = &http.Client{Transport: transport}
+	r.clock = func() time.Time { return now }
+
+	if err := r.ensureGraphBuilderStatus(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"foo-graph-builder.bar.svc:9080", "foo-graph-builder.bar.svc:9080"}, transport.hosts)
+	if assert.NotNil(t, updateservice.Status.GraphBuilder) {
+		assert.Equal(t, int64(412), updateservice.Status.GraphBuilder.Releases)
+		assert.True(t, now.Equal(updateservice.Status.GraphBuilder.LastCheckTime.Time))
+	}
+	condition := conditionsv1.FindStatusCondition(updateservice.Status.Conditions, conditionsv1.ConditionDegraded)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionFalse, condition.Status)
+	}
+
+	// With a lower threshold, the hour without a successful scrape degrades
+	// the UpdateService, without reading the endpoints again.
+	updateservice.Spec.ScrapeFailureThreshold = &metav1.Duration{Duration: 30 * time.Minute}
+	if err := r.ensureGraphBuilderStatus(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, transport.hosts, 2)
+	condition = conditionsv1.FindStatusCondition(updateservice.Status.Conditions, conditionsv1.ConditionDegraded)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Equal(t, "ScrapeFailing", condition.Reason)
+	}
+}
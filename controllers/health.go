@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+const (
+	// defaultResyncPeriod is how often each UpdateService is reconciled
+	// when nothing changes, unless configured.
+	defaultResyncPeriod = 5 * time.Minute
+
+	// livenessResyncPeriods is how many resync periods may pass without a
+	// completed reconcile before the operator is considered wedged. It is
+	// above the longest failure backoff of the controller, about 17 minutes.
+	livenessResyncPeriods = 4
+
+	// cacheSyncCheckTimeout bounds how long a readiness probe waits for the
+	// informer caches.
+	cacheSyncCheckTimeout = time.Second
+)
+
+// CacheSyncCheck returns a healthz.Checker which fails until the informer
+// caches have synced.
+func CacheSyncCheck(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncCheckTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("informer caches are not synced")
+		}
+		return nil
+	}
+}
+
+// LeaderElectionCheck returns a healthz.Checker which passes once this
+// replica leads, or once another replica holds the lease named lease. Until
+// the election has settled, that is while the lease is missing, unheld or
+// expired, replicas which do not lead are not ready.
+func LeaderElectionCheck(elected <-chan struct{}, c client.Reader, lease types.NamespacedName) healthz.Checker {
+	return func(req *http.Request) error {
+		select {
+		case <-elected:
+			return nil
+		default:
+		}
+
+		found := &coordinationv1.Lease{}
+		if err := c.Get(req.Context(), lease, found); err != nil {
+			return fmt.Errorf("failed to get leader election lease %s: %w", lease, err)
+		}
+		spec := found.Spec
+		if spec.HolderIdentity == nil || *spec.HolderIdentity == "" {
+			return fmt.Errorf("leader election lease %s is not held", lease)
+		}
+		if spec.RenewTime != nil && spec.LeaseDurationSeconds != nil {
+			expires := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+			if time.Now().After(expires) {
+				return fmt.Errorf("leader election lease %s of %s expired at %s", lease, *spec.HolderIdentity, expires.Format(time.RFC3339))
+			}
+		}
+		return nil
+	}
+}
+
+// LivenessCheck returns a healthz.Checker which fails when no reconcile has
+// completed within livenessResyncPeriods resync periods, while this replica
+// leads and UpdateServices exist.
+func (r *UpdateServiceReconciler) LivenessCheck(elected <-chan struct{}) healthz.Checker {
+	var mu sync.Mutex
+	// since is when a reconcile was last expected to have completed.
+	var since time.Time
+	return func(req *http.Request) error {
+		select {
+		case <-elected:
+		default:
+			// only the leader reconciles
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		now := r.now()
+		if since.IsZero() {
+			since = now
+		}
+		instances, err := listUpdateServices(req.Context(), r.Client, r.watchedNamespaces())
+		if err != nil {
+			// the readiness checks cover an unavailable cache
+			return nil
+		}
+		if len(instances) == 0 {
+			since = now
+			return nil
+		}
+		if last := r.lastReconcile(); last.After(since) {
+			since = last
+		}
+		if stalled := now.Sub(since); stalled > livenessResyncPeriods*r.resyncPeriod(req.Context()) {
+			return fmt.Errorf("no reconcile completed in %s", stalled.Round(time.Second))
+		}
+		return nil
+	}
+}
+
+// reconcileCompleted records when the latest reconcile returned.
+func (r *UpdateServiceReconciler) reconcileCompleted() {
+	r.lastReconciled.Store(r.now().UnixNano())
+}
+
+// lastReconcile returns when the latest reconcile returned, or the zero time.
+func (r *UpdateServiceReconciler) lastReconcile() time.Time {
+	nanos := r.lastReconciled.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
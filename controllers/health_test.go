@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+func TestCacheSyncCheck(t *testing.T) {
+	synced := false
+	check := CacheSyncCheck(&informertest.FakeInformers{Synced: &synced})
+	req := &http.Request{}
+	assert.Error(t, check(req.WithContext(context.TODO())))
+
+	synced = true
+	assert.NoError(t, check(req.WithContext(context.TODO())))
+}
+
+func TestLeaderElectionCheck(t *testing.T) {
+	name := types.NamespacedName{Namespace: testNamespace, Name: "48ad1930.openshift.io"}
+	holder := "updateservice-operator-7d9f8-abcde"
+	lease := func(holder string, renewed time.Duration) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: ptr.To[int32](137),
+				RenewTime:            &metav1.MicroTime{Time: time.Now().Add(-renewed)},
+			},
+		}
+	}
+	for _, tc := range []struct {
+		name        string
+		leads       bool
+		lease       *coordinationv1.Lease
+		expectedErr bool
+	}{
+		{name: "leader", leads: true},
+		{name: "non-leader before the election", expectedErr: true},
+		{name: "non-leader with a leader", lease: lease(holder, time.Minute)},
+		{name: "non-leader with a released lease", lease: lease("", time.Minute), expectedErr: true},
+		{name: "non-leader with an expired lease", lease: lease(holder, 5*time.Minute), expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := newFakeClientBuilder()
+			if tc.lease != nil {
+				builder = builder.WithObjects(tc.lease)
+			}
+			elected := make(chan struct{})
+			if tc.leads {
+				close(elected)
+			}
+			check := LeaderElectionCheck(elected, builder.Build(), name)
+			err := check((&http.Request{}).WithContext(context.TODO()))
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLivenessCheck(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler(updateservice, newSecret())
+	r.clock = func() time.Time { return now }
+	elected := make(chan struct{})
+	check := r.LivenessCheck(elected)
+	req := (&http.Request{}).WithContext(context.TODO())
+
+	// a replica that does not lead does not reconcile
+	now = now.Add(time.Hour)
+	assert.NoError(t, check(req))
+
+	close(elected)
+	assert.NoError(t, check(req))
+	now = now.Add(livenessResyncPeriods * defaultResyncPeriod)
+	assert.NoError(t, check(req))
+	now = now.Add(time.Minute)
+	assert.EqualError(t, check(req), "no reconcile completed in 21m0s")
+
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, check(req))
+
+	// without UpdateServices there is nothing to reconcile
+	if err := r.Client.Delete(context.TODO(), updateservice); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	assert.NoError(t, check(req))
+}
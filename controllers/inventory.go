@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// setInstanceLabel adds obj to the inventory of the UpdateService, and
+// reports whether its label had to be set.
+func setInstanceLabel(obj metav1.Object, instance *cv1.UpdateService) bool {
+	labels := obj.GetLabels()
+	if labels[instanceNameLabel] == instance.Name {
+		return false
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[instanceNameLabel] = instance.Name
+	obj.SetLabels(labels)
+	return true
+}
+
+// inventoryKey identifies a resource of the inventory of an UpdateService.
+type inventoryKey struct {
+	kind schema.GroupKind
+	name types.NamespacedName
+}
+
+// ensureInventory prunes the resources in the inventory of the UpdateService
+// which are no longer rendered, such as the ones left behind by a change of
+// their names. Only the resources controlled by the UpdateService are pruned.
+func (r *UpdateServiceReconciler) ensureInventory(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	rendered := map[inventoryKey]bool{}
+	for _, obj := range resources.objects() {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return err
+		}
+		rendered[inventoryKey{kind: gvk.GroupKind(), name: client.ObjectKeyFromObject(obj)}] = true
+	}
+
+	lists, err := r.resourceLists()
+	if err != nil {
+		r.handleErr(reqLogger, instance, "PruneResourcesFailed", err)
+		return err
+	}
+	for _, list := range lists {
+		if err := r.Client.List(ctx, list, client.InNamespace(instance.Namespace), client.MatchingLabels{instanceNameLabel: instance.Name}); err != nil {
+			r.handleErr(reqLogger, instance, "PruneResourcesFailed", err)
+			return err
+		}
+		err := meta.EachListItem(list, func(o runtime.Object) error {
+			obj := o.(client.Object)
+			if !metav1.IsControlledBy(obj, instance) {
+				return nil
+			}
+			gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+			if err != nil {
+				return err
+			}
+			if rendered[inventoryKey{kind: gvk.GroupKind(), name: client.ObjectKeyFromObject(obj)}] {
+				return nil
+			}
+			reqLogger.Info("Deleting orphaned "+gvk.Kind, "Kind", gvk.Kind, "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+			if err := r.delete(ctx, instance, obj); err != nil && !apiErrors.IsNotFound(err) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			r.handleErr(reqLogger, instance, "PruneResourcesFailed", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestEnsureInventory(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	scheme := newTestReconciler().Scheme
+	configMap := func(name string, labeled, controlled bool) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+		if labeled {
+			setInstanceLabel(cm, updateservice)
+		}
+		if controlled {
+			if err := controllerutil.SetControllerReference(updateservice, cm, scheme); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return cm
+	}
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered := resources.envConfig.DeepCopy()
+	if err := controllerutil.SetControllerReference(updateservice, rendered, scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestReconciler(
+		updateservice,
+		rendered,
+		configMap("orphaned", true, true),
+		configMap("unlabeled", false, true),
+		configMap("uncontrolled", true, false),
+	)
+	if err := r.ensureInventory(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, kept := range map[string]bool{
+		rendered.Name:  true,
+		"orphaned":     false,
+		"unlabeled":    true,
+		"uncontrolled": true,
+	} {
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: testNamespace}, &corev1.ConfigMap{})
+		if kept {
+			assert.NoError(t, err, name)
+		} else {
+			assert.True(t, apiErrors.IsNotFound(err), "%s should be pruned, got %v", name, err)
+		}
+	}
+}
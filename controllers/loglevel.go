@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// OperatorConfigMapName is the ConfigMap, in the operator namespace,
+	// holding settings that take effect without restarting the operator.
+	OperatorConfigMapName = "updateservice-operator-config"
+
+	// logLevelKey is the operator ConfigMap key for the log level.
+	logLevelKey = "logLevel"
+)
+
+// LogLevelReconciler sets the operator log level from the operator ConfigMap,
+// or else from the UpdateServiceOperatorConfig, and restores the default level
+// when neither sets it.
+type LogLevelReconciler struct {
+	Client    client.Client
+	Namespace string
+
+	// Level is the level of the operator logger.
+	Level zap.AtomicLevel
+	// Default is the level set by the command line.
+	Default zapcore.Level
+}
+
+func (r *LogLevelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	level := r.Default
+	config, err := findOperatorConfig(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if config.LogLevel != "" {
+		if level, err = parseLogLevel(config.LogLevel); err != nil {
+			log.Error(err, "Ignoring invalid log level", "UpdateServiceOperatorConfig", OperatorConfigName)
+			level = r.Default
+		}
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = r.Client.Get(ctx, req.NamespacedName, cm)
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if value, ok := cm.Data[logLevelKey]; err == nil && ok {
+		// an invalid level falls back to the one of the
+		// UpdateServiceOperatorConfig, or the default
+		if configMapLevel, err := parseLogLevel(value); err != nil {
+			log.Error(err, "Ignoring invalid log level", "ConfigMap", req.NamespacedName)
+		} else {
+			level = configMapLevel
+		}
+	}
+
+	if r.Level.Level() != level {
+		log.Info("Changing log level", "From", r.Level.Level().String(), "To", level.String())
+		r.Level.SetLevel(level)
+	}
+	return ctrl.Result{}, nil
+}
+
+// parseLogLevel parses the levels --zap-log-level accepts: debug, info,
+// error, or a logr verbosity which enables V(n) logs for every n up to it.
+func parseLogLevel(value string) (zapcore.Level, error) {
+	switch value {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	verbosity, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, error or a verbosity", value)
+	}
+	if verbosity < 0 {
+		return 0, fmt.Errorf("log verbosity %d is negative", verbosity)
+	}
+	return zapcore.Level(-verbosity), nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Every replica
+// runs it, since each has its own logger.
+func (r *LogLevelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	isOperatorConfig := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.Namespace && obj.GetName() == OperatorConfigMapName
+	})
+	operatorConfigMap := func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: r.Namespace, Name: OperatorConfigMapName}}}
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("loglevel").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(isOperatorConfig)).
+		Watches(&cv1.UpdateServiceOperatorConfig{}, handler.EnqueueRequestsFromMapFunc(operatorConfigMap)).
+		WithOptions(controller.Options{NeedLeaderElection: ptr.To(false)}).
+		Complete(r)
+}
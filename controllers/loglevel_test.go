@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected zapcore.Level
+		err      bool
+	}{
+		{value: "info", expected: zapcore.InfoLevel},
+		{value: "debug", expected: zapcore.DebugLevel},
+		{value: "error", expected: zapcore.ErrorLevel},
+		{value: "3", expected: zapcore.Level(-3)},
+		{value: "-1", err: true},
+		{value: "verbose", err: true},
+		{value: "warn", err: true},
+		{value: "panic", err: true},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			level, err := parseLogLevel(tc.value)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, level)
+		})
+	}
+}
+
+func TestLogLevelReconcile(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{logLevelKey: "debug"},
+	}
+	r := &LogLevelReconciler{
+		Client:    fake.NewClientBuilder().WithObjects(cm).Build(),
+		Namespace: testNamespace,
+		Level:     zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		Default:   zapcore.InfoLevel,
+	}
+	reconcile := func() {
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}}
+		if _, err := r.Reconcile(context.TODO(), request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcile()
+	assert.Equal(t, zapcore.DebugLevel, r.Level.Level())
+
+	// an invalid level falls back to the default
+	cm.Data[logLevelKey] = "verbose"
+	if err := r.Client.Update(context.TODO(), cm); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	assert.Equal(t, zapcore.InfoLevel, r.Level.Level())
+
+	// removing the ConfigMap restores the default
+	if err := r.Client.Delete(context.TODO(), cm); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	assert.Equal(t, zapcore.InfoLevel, r.Level.Level())
+}
+
+func TestLogLevelOperatorConfig(t *testing.T) {
+	config := &cv1.UpdateServiceOperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorConfigName},
+		Spec:       cv1.UpdateServiceOperatorConfigSpec{LogLevel: "error"},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorConfigMapName, Namespace: testNamespace},
+		Data:       map[string]string{logLevelKey: "debug"},
+	}
+	r := &LogLevelReconciler{
+		Client:    fake.NewClientBuilder().WithObjects(config, cm).Build(),
+		Namespace: testNamespace,
+		Level:     zap.NewAtomicLevelAt(zapcore.InfoLevel),
+		Default:   zapcore.InfoLevel,
+	}
+	reconcile := func() {
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}}
+		if _, err := r.Reconcile(context.TODO(), request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the operator ConfigMap overrides the operator configuration
+	reconcile()
+	assert.Equal(t, zapcore.DebugLevel, r.Level.Level())
+
+	// an invalid level falls back to the operator configuration
+	cm.Data[logLevelKey] = "verbose"
+	if err := r.Client.Update(context.TODO(), cm); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	assert.Equal(t, zapcore.ErrorLevel, r.Level.Level())
+
+	if err := r.Client.Delete(context.TODO(), cm); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	assert.Equal(t, zapcore.ErrorLevel, r.Level.Level())
+
+	// removing the level restores the default
+	config.Spec.LogLevel = ""
+	if err := r.Client.Update(context.TODO(), config); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	assert.Equal(t, zapcore.InfoLevel, r.Level.Level())
+}
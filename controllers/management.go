@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	routev1 "github.com/openshift/api/route/v1"
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// reportUnmanaged reports the status of an Unmanaged UpdateService, whose
+// resources the operator does not change.
+func (r *UpdateServiceReconciler) reportUnmanaged(ctx context.Context, reqLogger logr.Logger, instance, instanceCopy *cv1.UpdateService, resources *kubeResources) (ctrl.Result, error) {
+	reqLogger.Info("Not reconciling the resources of an Unmanaged UpdateService")
+
+	var err error
+	for _, f := range []func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error{
+		r.traced("ensureGraphServing", r.ensureGraphServing),
+		r.traced("ensureGraphBuilderStatus", r.ensureGraphBuilderStatus),
+	} {
+		err = f(ctx, reqLogger, instanceCopy, resources)
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
+			Type:    cv1.ConditionReconcileCompleted,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Unmanaged",
+			Message: "spec.managementState is Unmanaged, so the UpdateService resources are not reconciled",
+		})
+	}
+
+	r.recordConditionEvents(instanceCopy, instance.Status.Conditions)
+	observeStatus(instanceCopy, "")
+	r.updateStatus(ctx, reqLogger, instance, instanceCopy)
+	return ctrl.Result{RequeueAfter: r.resyncPeriod(ctx)}, err
+}
+
+// reconcileRemoved deletes the resources of a Removed UpdateService, and
+// reports their removal in its status.
+func (r *UpdateServiceReconciler) reconcileRemoved(ctx context.Context, reqLogger logr.Logger, instance, instanceCopy *cv1.UpdateService) (ctrl.Result, error) {
+	instanceCopy.Status.GraphServing = nil
+	instanceCopy.Status.GraphBuilder = nil
+
+	ctx, span := r.startStep(ctx, "deleteResources")
+	err := r.deleteResources(ctx, reqLogger, instanceCopy)
+	endSpan(span, err)
+	if err != nil {
+		r.handleErr(reqLogger, instanceCopy, "DeleteResourcesFailed", err)
+	} else {
+		conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
+			Type:    cv1.ConditionReconcileCompleted,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Removed",
+			Message: "spec.managementState is Removed, so the UpdateService resources were deleted",
+		})
+	}
+
+	r.recordConditionEvents(instanceCopy, instance.Status.Conditions)
+	observeStatus(instanceCopy, "")
+	r.updateStatus(ctx, reqLogger, instance, instanceCopy)
+	return ctrl.Result{}, err
+}
+
+// deleteResources deletes the resources controlled by the UpdateService, and
+// its ConsoleLinks. The console dashboard is shared by all UpdateServices and
+// is kept.
+func (r *UpdateServiceReconciler) deleteResources(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService) error {
+	lists, err := r.resourceLists()
+	if err != nil {
+		return err
+	}
+	for _, list := range lists {
+		if err := r.Client.List(ctx, list, client.InNamespace(instance.Namespace)); err != nil {
+			return err
+		}
+		err := meta.EachListItem(list, func(o runtime.Object) error {
+			obj := o.(client.Object)
+			if !metav1.IsControlledBy(obj, instance) {
+				return nil
+			}
+			kind := "Resource"
+			if gvk, err := apiutil.GVKForObject(obj, r.Scheme); err == nil {
+				kind = gvk.Kind
+			}
+			reqLogger.Info("Deleting "+kind, "Kind", kind, "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+			if err := r.delete(ctx, instance, obj); err != nil && !apiErrors.IsNotFound(err) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.deleteConsoleLinks(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name})
+}
+
+// resourceLists returns empty lists of the kinds of resources an UpdateService
+// may control. The Deployment goes first, so that its pods stop before their
+// configuration is deleted.
+func (r *UpdateServiceReconciler) resourceLists() ([]client.ObjectList, error) {
+	lists := []client.ObjectList{
+		&appsv1.DeploymentList{},
+		&corev1.PodList{},
+		&routev1.RouteList{},
+		&corev1.ServiceList{},
+		&policyv1.PodDisruptionBudgetList{},
+		&networkingv1.NetworkPolicyList{},
+		&corev1.ConfigMapList{},
+		&corev1.SecretList{},
+	}
+	for kind, list := range map[string]client.ObjectList{
+		monitoringv1.ServiceMonitorsKind: &monitoringv1.ServiceMonitorList{},
+		monitoringv1.PrometheusRuleKind:  &monitoringv1.PrometheusRuleList{},
+	} {
+		available, err := monitoringAvailable(r.Client.RESTMapper(), kind)
+		if err != nil {
+			return nil, err
+		}
+		if available {
+			lists = append(lists, list)
+		}
+	}
+	return append(lists, &rbacv1.RoleBindingList{}, &rbacv1.RoleList{}), nil
+}
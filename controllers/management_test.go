@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// setManagementState sets the managementState of the UpdateService and
+// reconciles it.
+func setManagementState(t *testing.T, r *UpdateServiceReconciler, updateservice *cv1.UpdateService, state cv1.ManagementState) *cv1.UpdateService {
+	request := newRequest(updateservice)
+	instance := &cv1.UpdateService{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	instance.Spec.ManagementState = state
+	if err := r.Client.Update(context.TODO(), instance); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	return instance
+}
+
+func TestReconcileUnmanaged(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler(updateservice, newSecret())
+	request := newRequest(updateservice)
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	// patch the Deployment by hand
+	deployment := &appsv1.Deployment{}
+	name := types.NamespacedName{Name: nameDeployment(updateservice), Namespace: updateservice.Namespace}
+	if err := r.Client.Get(context.TODO(), name, deployment); err != nil {
+		t.Fatal(err)
+	}
+	deployment.Spec.Replicas = ptr.To[int32](5)
+	if err := r.Client.Update(context.TODO(), deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	instance := setManagementState(t, r, updateservice, cv1.ManagementStateUnmanaged)
+
+	if err := r.Client.Get(context.TODO(), name, deployment); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int32(5), *deployment.Spec.Replicas)
+	completed := conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionReconcileCompleted)
+	if assert.NotNil(t, completed) {
+		assert.Equal(t, corev1.ConditionTrue, completed.Status)
+		assert.Equal(t, "Unmanaged", completed.Reason)
+	}
+	assert.NotNil(t, conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionRegistryCACertFound))
+
+	// returning to Managed reverts the hand patch
+	setManagementState(t, r, updateservice, cv1.ManagementStateManaged)
+	if err := r.Client.Get(context.TODO(), name, deployment); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, updateservice.Spec.Replicas, *deployment.Spec.Replicas)
+}
+
+func TestReconcileRemoved(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler(updateservice, newSecret())
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+
+	instance := setManagementState(t, r, updateservice, cv1.ManagementStateRemoved)
+
+	for name, obj := range map[string]client.Object{
+		nameDeployment(updateservice):          &appsv1.Deployment{},
+		namePolicyEngineService(updateservice): &corev1.Service{},
+		nameGraphBuilderService(updateservice): &corev1.Service{},
+		nameConfig(updateservice):              &corev1.ConfigMap{},
+		nameEnvConfig(updateservice):           &corev1.ConfigMap{},
+		namePullSecretCopy(updateservice):      &corev1.Secret{},
+	} {
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: updateservice.Namespace}, obj)
+		assert.True(t, apiErrors.IsNotFound(err), "expected %s to be deleted, got %v", name, err)
+	}
+	// the source pull secret is not owned by the UpdateService
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: namePullSecret, Namespace: OpenshiftConfigNamespace}, &corev1.Secret{}); err != nil {
+		t.Fatal(err)
+	}
+
+	completed := conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionReconcileCompleted)
+	if assert.NotNil(t, completed) {
+		assert.Equal(t, corev1.ConditionTrue, completed.Status)
+		assert.Equal(t, "Removed", completed.Reason)
+	}
+	assert.Empty(t, instance.Status.PolicyEngineURI)
+}
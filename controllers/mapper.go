@@ -4,6 +4,7 @@ import (
 	"context"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -14,17 +15,21 @@ import (
 )
 
 type mapper struct {
-	client    client.Client
-	namespace string
+	client client.Client
+	// namespaces are the watched namespaces, see
+	// UpdateServiceReconciler.WatchNamespaces.
+	namespaces []string
 }
 
 // Map will return a reconcile request for a UpdateService if the event is for a
-// ImageConfigName Image or a ConfigMap referenced by AdditionalTrustedCA.Name.
+// ImageConfigName Image, a ConfigMap referenced by AdditionalTrustedCA.Name, the
+// cluster-wide Proxy, or the UpdateServiceOperatorConfig.
+// ConfigMaps may be watched for their metadata only.
 func (m *mapper) Map(ctx context.Context, obj client.Object) []reconcile.Request {
-	if cm, ok := obj.(*corev1.ConfigMap); ok {
+	if isConfigMap(obj) {
 		// There is already a watch on local configMap as a secondary resource
 		// This watch is for the source configMap in openshift-config namespace
-		if cm.Namespace != OpenshiftConfigNamespace {
+		if obj.GetNamespace() != OpenshiftConfigNamespace {
 			return []reconcile.Request{}
 		}
 		image := &apicfgv1.Image{}
@@ -35,10 +40,18 @@ func (m *mapper) Map(ctx context.Context, obj client.Object) []reconcile.Request
 			}
 			return []reconcile.Request{}
 		}
-		if image.Spec.AdditionalTrustedCA.Name == cm.ObjectMeta.Name {
+		if image.Spec.AdditionalTrustedCA.Name == obj.GetName() {
 			// If the object is configMap that we are watching, requeue all UpdateService instances
 			return m.requeueUpdateServices()
 		}
+	} else if _, ok := obj.(*cv1.UpdateServiceOperatorConfig); ok {
+		// Requeue all UpdateService instances, with the new defaults
+		return m.requeueUpdateServices()
+	} else if proxy, ok := obj.(*apicfgv1.Proxy); ok {
+		if proxy.Name == ProxyConfigName {
+			// Requeue all UpdateService instances, with the new proxy
+			return m.requeueUpdateServices()
+		}
 	} else if img, ok := obj.(*apicfgv1.Image); ok {
 		// Check if this is the image we are interested in
 		if img.Name == defaults.ImageConfigName && img.Namespace == "" {
@@ -49,14 +62,24 @@ func (m *mapper) Map(ctx context.Context, obj client.Object) []reconcile.Request
 	return []reconcile.Request{}
 }
 
+// isConfigMap reports whether obj is a ConfigMap, or the metadata of one.
+func isConfigMap(obj client.Object) bool {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		return true
+	case *metav1.PartialObjectMetadata:
+		return o.GroupVersionKind() == corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	}
+	return false
+}
+
 func (m *mapper) requeueUpdateServices() []reconcile.Request {
-	updateservices := &cv1.UpdateServiceList{}
-	err := m.client.List(context.TODO(), updateservices, client.InNamespace(m.namespace))
+	updateservices, err := listUpdateServices(context.TODO(), m.client, m.namespaces)
 	if err != nil {
 		return []reconcile.Request{}
 	}
 	var requests []reconcile.Request
-	for _, updateservice := range updateservices.Items {
+	for _, updateservice := range updateservices {
 		requests = append(requests, reconcile.Request{
 			NamespacedName: types.NamespacedName{
 				Name:      updateservice.Name,
@@ -11,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -18,7 +19,9 @@ func TestMap(t *testing.T) {
 	tests := []struct {
 		name             string
 		image            *apicfgv1.Image
+		proxy            *apicfgv1.Proxy
 		configMap        *corev1.ConfigMap
+		metadata         *metav1.PartialObjectMetadata
 		existingObjs     []runtime.Object
 		expectedRequests []reconcile.Request
 	}{
@@ -81,6 +84,37 @@ func TestMap(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "IncorrectProxyNameNoRequeue",
+			proxy: &apicfgv1.Proxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: testName,
+				},
+			},
+			existingObjs: []runtime.Object{
+				newDefaultUpdateService(),
+			},
+			expectedRequests: []reconcile.Request{},
+		},
+		{
+			name: "ProxyRequeue",
+			proxy: &apicfgv1.Proxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: ProxyConfigName,
+				},
+			},
+			existingObjs: []runtime.Object{
+				newDefaultUpdateService(),
+			},
+			expectedRequests: []reconcile.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      newDefaultUpdateService().Name,
+						Namespace: newDefaultUpdateService().Namespace,
+					},
+				},
+			},
+		},
 		{
 			name: "IncorrectConfigMapNamespaceNoRequeue",
 			configMap: &corev1.ConfigMap{
@@ -115,14 +149,61 @@ func TestMap(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ConfigMapMetadataRequeue",
+			metadata: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      testConfigMap,
+					Namespace: OpenshiftConfigNamespace,
+				},
+			},
+			existingObjs: []runtime.Object{
+				newDefaultUpdateService(),
+				newImage(),
+			},
+			expectedRequests: []reconcile.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      newDefaultUpdateService().Name,
+						Namespace: newDefaultUpdateService().Namespace,
+					},
+				},
+			},
+		},
+		{
+			name: "OtherMetadataNoRequeue",
+			metadata: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Secret",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      testConfigMap,
+					Namespace: OpenshiftConfigNamespace,
+				},
+			},
+			existingObjs: []runtime.Object{
+				newDefaultUpdateService(),
+				newImage(),
+			},
+			expectedRequests: []reconcile.Request{},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			r := newTestReconciler(test.existingObjs...)
-			m := mapper{r.Client, ""}
+			m := mapper{r.Client, []string{cache.AllNamespaces}}
 			var reqs []reconcile.Request
 			if test.image != nil {
 				reqs = m.Map(context.TODO(), test.image)
+			} else if test.proxy != nil {
+				reqs = m.Map(context.TODO(), test.proxy)
+			} else if test.metadata != nil {
+				reqs = m.Map(context.TODO(), test.metadata)
 			} else {
 				reqs = m.Map(context.TODO(), test.configMap)
 			}
@@ -130,3 +211,36 @@ func TestMap(t *testing.T) {
 		})
 	}
 }
+
+func TestMapWatchedNamespaces(t *testing.T) {
+	var objs []runtime.Object
+	for _, ns := range []string{"tenant-a", "tenant-b", "tenant-c"} {
+		updateservice := newDefaultUpdateService()
+		updateservice.Namespace = ns
+		objs = append(objs, updateservice)
+	}
+	objs = append(objs, newImage())
+	r := newTestReconciler(objs...)
+	request := func(ns string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Name: testName, Namespace: ns}}
+	}
+
+	for name, test := range map[string]struct {
+		namespaces []string
+		expected   []reconcile.Request
+	}{
+		"list": {
+			namespaces: []string{"tenant-a", "tenant-c"},
+			expected:   []reconcile.Request{request("tenant-a"), request("tenant-c")},
+		},
+		"all": {
+			namespaces: []string{cache.AllNamespaces},
+			expected:   []reconcile.Request{request("tenant-a"), request("tenant-b"), request("tenant-c")},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := mapper{r.Client, test.namespaces}
+			assert.ElementsMatch(t, test.expected, m.Map(context.TODO(), newImage()))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	metricsNamespace = "updateservice"
+
+	// namespaceLabel is the label of the namespace of an UpdateService. It is
+	// not namespace, which Prometheus sets to the namespace of the operator it
+	// scrapes.
+	namespaceLabel = "updateservice_namespace"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_total",
+		Help:      "Number of UpdateService reconciles, by result.",
+	}, []string{namespaceLabel, "name", "result"})
+
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of UpdateService reconciles.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{namespaceLabel, "name"})
+
+	resourceChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "resource_changes_total",
+		Help:      "Number of resources the operator created, updated or deleted for an UpdateService, by kind and action.",
+	}, []string{namespaceLabel, "name", "kind", "action"})
+
+	conditionStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "condition",
+		Help:      "Current UpdateService conditions; 1 for the current status of each condition type, 0 for the others.",
+	}, []string{namespaceLabel, "name", "type", "status"})
+
+	graphDataInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "graph_data_info",
+		Help:      "The graph-data image and the digest resolved from it that the UpdateService rolls out; always 1.",
+	}, []string{namespaceLabel, "name", "image", "digest"})
+
+	graphDataDigestChanged = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "graph_data_digest_change_timestamp_seconds",
+		Help:      "Unix time the UpdateService's current graph-data digest was first resolved. Subtract it from time() for the time since the digest last changed.",
+	}, []string{namespaceLabel, "name"})
+
+	policyEngineURISet = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "policy_engine_uri_set",
+		Help:      "Whether status.policyEngineURI is set on the UpdateService; 1 if set, 0 if not.",
+	}, []string{namespaceLabel, "name"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileTotal,
+		reconcileDuration,
+		resourceChangesTotal,
+		conditionStatus,
+		graphDataInfo,
+		graphDataDigestChanged,
+		policyEngineURISet,
+	)
+}
+
+// observeReconcile records the result and duration of a reconcile which
+// started at start.
+func observeReconcile(name types.NamespacedName, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	reconcileTotal.WithLabelValues(name.Namespace, name.Name, result).Inc()
+	reconcileDuration.WithLabelValues(name.Namespace, name.Name).Observe(time.Since(start).Seconds())
+}
+
+// observeResourceChange counts a resource the operator changed for instance.
+func observeResourceChange(instance *cv1.UpdateService, kind, action string) {
+	resourceChangesTotal.WithLabelValues(instance.Namespace, instance.Name, kind, strings.ToLower(action)).Inc()
+}
+
+// observeStatus records the conditions and PolicyEngineURI of instance, and,
+// when digest is set, the graph-data digest being rolled out.
+func observeStatus(instance *cv1.UpdateService, digest string) {
+	labels := prometheus.Labels{namespaceLabel: instance.Namespace, "name": instance.Name}
+
+	conditionStatus.DeletePartialMatch(labels)
+	for _, condition := range instance.Status.Conditions {
+		for _, status := range []corev1.ConditionStatus{corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown} {
+			value := 0.0
+			if condition.Status == status {
+				value = 1
+			}
+			conditionStatus.WithLabelValues(instance.Namespace, instance.Name, string(condition.Type), string(status)).Set(value)
+		}
+	}
+
+	uriSet := 0.0
+	if instance.Status.PolicyEngineURI != "" {
+		uriSet = 1
+	}
+	policyEngineURISet.With(labels).Set(uriSet)
+
+	if digest == "" {
+		return
+	}
+	graphDataInfo.DeletePartialMatch(labels)
+	graphDataInfo.WithLabelValues(instance.Namespace, instance.Name, instance.Spec.GraphDataImage, digest).Set(1)
+	if revision := findGraphDataRevision(instance.Status.GraphDataHistory, digest); revision != nil {
+		graphDataDigestChanged.With(labels).Set(float64(revision.FirstSeen.Unix()))
+	}
+}
+
+// forgetMetrics removes the metrics of a deleted UpdateService.
+func forgetMetrics(name types.NamespacedName) {
+	labels := prometheus.Labels{namespaceLabel: name.Namespace, "name": name.Name}
+	for _, vec := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{
+		reconcileTotal,
+		reconcileDuration,
+		resourceChangesTotal,
+		conditionStatus,
+		graphDataInfo,
+		graphDataDigestChanged,
+		policyEngineURISet,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+}
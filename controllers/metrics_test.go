@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// countSeries returns the number of series of collector for an UpdateService.
+func countSeries(t *testing.T, collector prometheus.Collector, name types.NamespacedName) int {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, family := range families {
+		for _, m := range family.Metric {
+			labels := map[string]string{}
+			for _, label := range m.Label {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels[namespaceLabel] == name.Namespace && labels["name"] == name.Name {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestObserveStatus(t *testing.T) {
+	digest := "sha256:" + fmt.Sprintf("%064d", 1)
+	firstSeen := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	updateservice := newDefaultUpdateService()
+	updateservice.Name = "observe-status"
+	updateservice.Status.PolicyEngineURI = "https://example.com"
+	updateservice.Status.Conditions = []conditionsv1.Condition{
+		{Type: cv1.ConditionReconcileCompleted, Status: corev1.ConditionTrue},
+	}
+	updateservice.Status.GraphDataHistory = []cv1.GraphDataRevision{
+		{Image: "quay.io/cincinnati/graph-data@" + digest, FirstSeen: metav1.NewTime(firstSeen)},
+	}
+
+	observeStatus(updateservice, digest)
+
+	ns, name := updateservice.Namespace, updateservice.Name
+	assert.Equal(t, 1.0, testutil.ToFloat64(conditionStatus.WithLabelValues(ns, name, "ReconcileCompleted", "True")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(conditionStatus.WithLabelValues(ns, name, "ReconcileCompleted", "False")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(policyEngineURISet.WithLabelValues(ns, name)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(graphDataInfo.WithLabelValues(ns, name, testGraphDataImage, digest)))
+	assert.Equal(t, float64(firstSeen.Unix()), testutil.ToFloat64(graphDataDigestChanged.WithLabelValues(ns, name)))
+
+	// a new digest replaces the info series
+	observeStatus(updateservice, "sha256:"+fmt.Sprintf("%064d", 2))
+	assert.Equal(t, 1, countSeries(t, graphDataInfo, types.NamespacedName{Namespace: ns, Name: name}))
+
+	forgetMetrics(types.NamespacedName{Namespace: ns, Name: name})
+	assert.Equal(t, 0, countSeries(t, conditionStatus, types.NamespacedName{Namespace: ns, Name: name}))
+	assert.Equal(t, 0, countSeries(t, graphDataInfo, types.NamespacedName{Namespace: ns, Name: name}))
+}
+
+func TestReconcileMetrics(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	updateservice.Name = "reconcile-metrics"
+	r := newTestReconciler(updateservice, newSecret())
+	request := newRequest(updateservice)
+	name := request.NamespacedName
+
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(reconcileTotal.WithLabelValues(name.Namespace, name.Name, "success")))
+	assert.Equal(t, 1, countSeries(t, reconcileDuration, name))
+	assert.Equal(t, 1.0, testutil.ToFloat64(resourceChangesTotal.WithLabelValues(name.Namespace, name.Name, "Deployment", "created")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(resourceChangesTotal.WithLabelValues(name.Namespace, name.Name, "Service", "created")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(policyEngineURISet.WithLabelValues(name.Namespace, name.Name)))
+
+	// the UpdateService was deleted
+	if err := r.Client.Delete(context.TODO(), updateservice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+	for _, collector := range []prometheus.Collector{reconcileTotal, reconcileDuration, resourceChangesTotal, conditionStatus, policyEngineURISet} {
+		assert.Equal(t, 0, countSeries(t, collector, name))
+	}
+}
+
+func TestReconcileMetricsDigestPinnedImage(t *testing.T) {
+	digest := "sha256:" + fmt.Sprintf("%064d", 3)
+	updateservice := newDefaultUpdateService()
+	updateservice.Name = "digest-pinned"
+	updateservice.Spec.GraphDataImage = "quay.io/cincinnati/graph-data@" + digest
+	r := newTestReconciler(updateservice, newSecret())
+	request := newRequest(updateservice)
+	name := request.NamespacedName
+	defer forgetMetrics(name)
+
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(graphDataInfo.WithLabelValues(name.Namespace, name.Name, updateservice.Spec.GraphDataImage, digest)))
+	assert.Equal(t, 1, countSeries(t, graphDataDigestChanged, name))
+}
+
+// TestPrometheusRuleMetricLabels checks that the alerts on the operator's
+// metrics select the UpdateService by the labels which the metrics export,
+// rather than by namespace, which Prometheus sets to the scraped target's.
+func TestPrometheusRuleMetricLabels(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	updateservice.Name = "rule-metric-labels"
+	updateservice.Namespace = "tenant"
+	digest := "sha256:" + fmt.Sprintf("%064d", 3)
+	updateservice.Status.GraphDataHistory = []cv1.GraphDataRevision{
+		{Image: "quay.io/cincinnati/graph-data@" + digest, FirstSeen: metav1.Now()},
+	}
+	observeStatus(updateservice, digest)
+	defer forgetMetrics(types.NamespacedName{Namespace: updateservice.Namespace, Name: updateservice.Name})
+
+	exprs := map[string]string{}
+	k := &kubeResources{}
+	for _, rule := range k.newPrometheusRule(updateservice).Spec.Groups[0].Rules {
+		exprs[rule.Alert] = rule.Expr.String()
+	}
+
+	for alert, collector := range map[string]prometheus.Collector{
+		"UpdateServiceGraphDataStale":   graphDataDigestChanged,
+		"UpdateServiceRouteNotAdmitted": policyEngineURISet,
+	} {
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(collector)
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		selected := false
+		for _, family := range families {
+			for _, m := range family.Metric {
+				labels := map[string]string{}
+				for _, label := range m.Label {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if labels[namespaceLabel] != updateservice.Namespace || labels["name"] != updateservice.Name {
+					continue
+				}
+				assert.NotContains(t, labels, "namespace", "Prometheus overwrites the namespace label of %s", family.GetName())
+				selector := fmt.Sprintf(`%s=%q, name=%q`, namespaceLabel, updateservice.Namespace, updateservice.Name)
+				assert.Contains(t, exprs[alert], family.GetName()+"{"+selector+"}")
+				selected = true
+			}
+		}
+		assert.True(t, selected, "%s selects no series", alert)
+	}
+}
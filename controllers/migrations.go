@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	routev1 "github.com/openshift/api/route/v1"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// clusterTrustedCASchemaVersion is the schema version from which every
+// UpdateService has its own cluster CA ConfigMap.
+const clusterTrustedCASchemaVersion = 3
+
+// migration upgrades the resources of an UpdateService, which an earlier
+// operator version reconciled, to a schema version.
+type migration struct {
+	// version is the schema version the resources are at once migrated.
+	version int32
+	// name describes the migration in logs and conditions.
+	name string
+	// migrate must be idempotent: it runs again when the schema version
+	// could not be recorded, and for UpdateServices without resources.
+	migrate func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error
+}
+
+// migrations returns the migrations, ordered by their schema versions. A
+// migration is appended with the next schema version, and is never removed
+// or renumbered, as UpdateServices may be at any earlier schema version.
+func (r *UpdateServiceReconciler) migrations() []migration {
+	return []migration{
+		{version: 1, name: "FieldManager", migrate: r.migrateFieldManager},
+		{version: 2, name: "PolicyEngineRoute", migrate: r.migrateLegacyRoute},
+		{version: clusterTrustedCASchemaVersion, name: "ClusterTrustedCA", migrate: r.migrateClusterTrustedCA},
+	}
+}
+
+// schemaVersion returns the schema version of the resources this operator
+// renders, which is the version of its last migration.
+func (r *UpdateServiceReconciler) schemaVersion() int32 {
+	migrations := r.migrations()
+	return migrations[len(migrations)-1].version
+}
+
+// ensureMigrations runs the migrations after the schema version recorded in
+// the status, which is raised after each of them. A failed migration is
+// retried by the next reconcile, and the resources are not applied until it
+// succeeds.
+func (r *UpdateServiceReconciler) ensureMigrations(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	from := instance.Status.SchemaVersion
+	for _, m := range r.migrations() {
+		if m.version <= instance.Status.SchemaVersion {
+			continue
+		}
+		reqLogger.Info("Migrating resources", "SchemaVersion", m.version, "Migration", m.name)
+		if err := m.migrate(ctx, reqLogger, instance, resources); err != nil {
+			err = fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+			conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+				Type:    cv1.ConditionMigrationsCompleted,
+				Status:  corev1.ConditionFalse,
+				Reason:  "MigrationFailed",
+				Message: err.Error(),
+			})
+			r.handleErr(reqLogger, instance, "MigrationFailed", err)
+			return err
+		}
+		instance.Status.SchemaVersion = m.version
+	}
+
+	current := r.schemaVersion()
+	if from > current {
+		// the UpdateService was reconciled by a later operator version,
+		// whose migrations are expected to be idempotent when it returns
+		reqLogger.Info("Resources were migrated to a later schema version", "SchemaVersion", from)
+		instance.Status.SchemaVersion = current
+	}
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+		Type:    cv1.ConditionMigrationsCompleted,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Migrated",
+		Message: fmt.Sprintf("The resources were migrated to schema version %d", current),
+	})
+	return nil
+}
+
+// migrateFieldManager takes over the fields of the rendered resources which
+// earlier operator versions wrote with client-side updates, so that the
+// operator's applies own them rather than conflict with them.
+func (r *UpdateServiceReconciler) migrateFieldManager(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	for _, obj := range resources.objects() {
+		if _, ok := obj.(*routev1.Route); ok {
+			// the operator does not apply the host and the TLS
+			// configuration of the Route, which its next apply would
+			// remove once taken over; the fields it applies are owned
+			// with the legacy field manager, without conflicts
+			continue
+		}
+		// findApplied sets the kind, which is not part of the rendering
+		obj = obj.DeepCopyObject().(client.Object)
+		found, err := r.findApplied(ctx, obj)
+		if meta.IsNoMatchError(err) {
+			// the monitoring CRDs are not installed
+			continue
+		} else if err != nil {
+			return err
+		}
+		if found == nil {
+			continue
+		}
+		patch, err := csaupgrade.UpgradeManagedFieldsPatch(found, sets.New(legacyFieldManager), fieldManager)
+		if err != nil {
+			return err
+		}
+		if patch == nil {
+			continue
+		}
+		kind := obj.GetObjectKind().GroupVersionKind().Kind
+		reqLogger.Info("Taking over fields of "+kind, "Kind", kind, "Namespace", found.GetNamespace(), "Name", found.GetName())
+		if err := r.Client.Patch(ctx, found, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyRoute replaces the Route of earlier operator versions, named
+// after the policy engine Service, with the policy engine Route. The new Route
+// keeps the host and the TLS configuration of the legacy one, so that clients
+// and custom certificates keep working.
+func (r *UpdateServiceReconciler) migrateLegacyRoute(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	legacy := &routev1.Route{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: legacyPolicyEngineRouteName(instance), Namespace: instance.Namespace}, legacy)
+	if apiErrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(legacy, instance) {
+		return nil
+	}
+
+	// without the Route exposure, the legacy Route is only deleted
+	if resources.policyEngineRoute != nil {
+		route := resources.policyEngineRoute.DeepCopy()
+		err = r.Client.Get(ctx, types.NamespacedName{Name: route.Name, Namespace: route.Namespace}, &routev1.Route{})
+		if apiErrors.IsNotFound(err) {
+			if err := controllerutil.SetControllerReference(instance, route, r.Scheme); err != nil {
+				return err
+			}
+			route.Spec.Host = legacy.Spec.Host
+			route.Spec.TLS = legacy.Spec.TLS
+			reqLogger.Info("Migrating Route", "Kind", "Route", "Namespace", route.Namespace, "Name", route.Name, "LegacyName", legacy.Name)
+			if err := r.create(ctx, instance, route); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	reqLogger.Info("Deleting Route", "Kind", "Route", "Namespace", legacy.Namespace, "Name", legacy.Name)
+	if err := r.delete(ctx, instance, legacy); err != nil && !apiErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// migrateClusterTrustedCA releases the cluster CA ConfigMap which earlier
+// operator versions shared between the UpdateServices of a namespace, with
+// the first of them as its controller. Each UpdateService now has its own.
+// The shared ConfigMap is deleted by ensureLegacyClusterTrustedCA, once no
+// Deployment mounts it anymore.
+func (r *UpdateServiceReconciler) migrateClusterTrustedCA(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	legacy := &corev1.ConfigMap{}
+	// the legacy ConfigMap has no inventory label, so it is not cached
+	err := r.apiReader().Get(ctx, types.NamespacedName{Name: legacyClusterTrustedCAName, Namespace: instance.Namespace}, legacy)
+	if apiErrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if legacy.Labels[injectTrustedCABundleLabel] != "true" {
+		// not created by the operator
+		return nil
+	}
+
+	if metav1.IsControlledBy(legacy, instance) {
+		patch := client.MergeFrom(legacy.DeepCopy())
+		var owners []metav1.OwnerReference
+		for _, owner := range legacy.OwnerReferences {
+			if owner.UID != instance.UID {
+				owners = append(owners, owner)
+			}
+		}
+		legacy.OwnerReferences = owners
+		reqLogger.Info("Releasing ConfigMap", "Kind", "ConfigMap", "Namespace", legacy.Namespace, "Name", legacy.Name)
+		if err := r.Client.Patch(ctx, legacy, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureLegacyClusterTrustedCA deletes the cluster CA ConfigMap which earlier
+// operator versions shared between the UpdateServices of a namespace, once it
+// was released and the Deployments of the namespace no longer mount it. It
+// runs after the Deployment was applied, as the migration runs before.
+func (r *UpdateServiceReconciler) ensureLegacyClusterTrustedCA(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	legacy := &corev1.ConfigMap{}
+	// the legacy ConfigMap has no inventory label, so it is not cached
+	err := r.apiReader().Get(ctx, types.NamespacedName{Name: legacyClusterTrustedCAName, Namespace: instance.Namespace}, legacy)
+	if apiErrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if legacy.Labels[injectTrustedCABundleLabel] != "true" || len(legacy.OwnerReferences) > 0 {
+		// not created by the operator, or not released yet
+		return nil
+	}
+
+	instances := &cv1.UpdateServiceList{}
+	if err := r.Client.List(ctx, instances, client.InNamespace(instance.Namespace)); err != nil {
+		return err
+	}
+	for i := range instances.Items {
+		deployment := &appsv1.Deployment{}
+		// Deployments of earlier operator versions have no inventory label
+		err := r.apiReader().Get(ctx, types.NamespacedName{Name: nameDeployment(&instances.Items[i]), Namespace: instance.Namespace}, deployment)
+		if apiErrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if mountsConfigMap(deployment, legacy.Name) {
+			return nil
+		}
+	}
+	reqLogger.Info("Deleting ConfigMap", "Kind", "ConfigMap", "Namespace", legacy.Namespace, "Name", legacy.Name)
+	if err := r.delete(ctx, instance, legacy); err != nil && !apiErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// mountsConfigMap returns whether the pod template of deployment has a
+// volume of the ConfigMap name.
+func mountsConfigMap(deployment *appsv1.Deployment, name string) bool {
+	for _, v := range deployment.Spec.Template.Spec.Volumes {
+		if v.ConfigMap != nil && v.ConfigMap.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	routev1 "github.com/openshift/api/route/v1"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func TestMigrations(t *testing.T) {
+	r := newTestReconciler()
+	// schema versions are consecutive, so that every migration runs
+	for i, m := range r.migrations() {
+		assert.Equal(t, int32(i+1), m.version, m.name)
+		assert.NotEmpty(t, m.name)
+	}
+	assert.Equal(t, int32(len(r.migrations())), r.schemaVersion())
+}
+
+// newLegacyRoute returns the Route of earlier operator versions, with a
+// custom host and certificate.
+func newLegacyRoute(t *testing.T, updateservice *cv1.UpdateService) *routev1.Route {
+	legacy := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: legacyPolicyEngineRouteName(updateservice), Namespace: testNamespace},
+		Spec: routev1.RouteSpec{
+			Host: "updates.example.com",
+			To:   routev1.RouteTargetReference{Kind: "Service", Name: namePolicyEngineService(updateservice)},
+			TLS: &routev1.TLSConfig{
+				Termination: routev1.TLSTerminationEdge,
+				Certificate: "custom-certificate",
+				Key:         "custom-key",
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(updateservice, legacy, newTestReconciler().Scheme); err != nil {
+		t.Fatal(err)
+	}
+	return legacy
+}
+
+func TestMigrateLegacyRoute(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	legacy := newLegacyRoute(t, updateservice)
+	r := newTestReconciler(updateservice, legacy)
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// migrations are idempotent
+	for i := 0; i < 2; i++ {
+		if err := r.migrateLegacyRoute(context.TODO(), log, updateservice, resources); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the migrated host is kept by later reconciles
+	if err := r.ensurePolicyEngineRoute(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	found := &routev1.Route{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: namePolicyEngineRoute(updateservice), Namespace: testNamespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, legacy.Spec.Host, found.Spec.Host)
+	assert.Equal(t, legacy.Spec.TLS, found.Spec.TLS)
+	assert.Equal(t, updateservice.Name, found.Labels[instanceNameLabel])
+	verifyOwnerReference(t, found.OwnerReferences[0], updateservice)
+
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: legacy.Name, Namespace: testNamespace}, &routev1.Route{})
+	assert.True(t, apiErrors.IsNotFound(err), "the legacy Route should be deleted, got %v", err)
+}
+
+func TestMigrateFieldManager(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envConfig := resources.envConfig.DeepCopy()
+	envConfig.ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:    legacyFieldManager,
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{}}`)},
+	}}
+	r := newTestReconciler(updateservice, envConfig)
+
+	if err := r.migrateFieldManager(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	found := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(envConfig), found); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, found.ManagedFields, 1) {
+		assert.Equal(t, fieldManager, found.ManagedFields[0].Manager)
+		assert.Equal(t, metav1.ManagedFieldsOperationApply, found.ManagedFields[0].Operation)
+	}
+}
+
+func TestReconcileMigrations(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	legacy := newLegacyRoute(t, updateservice)
+	routeErr := errors.New("route unavailable")
+	failing := true
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(updateservice, newSecret(), legacy).WithStatusSubresource(&cv1.UpdateService{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if _, ok := obj.(*routev1.Route); ok && failing && key.Name == legacy.Name {
+					return routeErr
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+			Patch: fakeApply,
+		}).Build()
+	request := newRequest(updateservice)
+	reconcile := func() *cv1.UpdateService {
+		t.Helper()
+		_, err := r.Reconcile(context.TODO(), request)
+		if failing {
+			assert.ErrorIs(t, err, routeErr)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		instance := &cv1.UpdateService{}
+		if err := r.Client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+			t.Fatal(err)
+		}
+		return instance
+	}
+
+	// a failed migration is reported, and holds back the resources
+	instance := reconcile()
+	assert.Equal(t, int32(1), instance.Status.SchemaVersion, "the migrations before the failed one are recorded")
+	c := conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionMigrationsCompleted)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, corev1.ConditionFalse, c.Status)
+		assert.Equal(t, "MigrationFailed", c.Reason)
+		assert.Equal(t, "migration 2 (PolicyEngineRoute) failed: route unavailable", c.Message)
+	}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, &appsv1.Deployment{})
+	assert.True(t, apiErrors.IsNotFound(err), "the Deployment should not be applied before the migrations, got %v", err)
+
+	failing = false
+	instance = reconcile()
+	assert.Equal(t, r.schemaVersion(), instance.Status.SchemaVersion)
+	c = conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionMigrationsCompleted)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, corev1.ConditionTrue, c.Status)
+		assert.Equal(t, "Migrated", c.Reason)
+		assert.Equal(t, fmt.Sprintf("The resources were migrated to schema version %d", r.schemaVersion()), c.Message)
+	}
+	assert.True(t, conditionsv1.IsStatusConditionTrue(instance.Status.Conditions, cv1.ConditionReconcileCompleted))
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: legacy.Name, Namespace: testNamespace}, &routev1.Route{})
+	assert.True(t, apiErrors.IsNotFound(err), "the legacy Route should be deleted, got %v", err)
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, &appsv1.Deployment{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newLegacyClusterTrustedCA returns the cluster CA ConfigMap which earlier
+// operator versions shared between the UpdateServices of a namespace.
+func newLegacyClusterTrustedCA(t *testing.T, controller *cv1.UpdateService) *corev1.ConfigMap {
+	legacy := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      legacyClusterTrustedCAName,
+			Namespace: testNamespace,
+			Labels:    map[string]string{injectTrustedCABundleLabel: "true"},
+		},
+		Data: map[string]string{NameClusterCertConfigMapKey: "ca"},
+	}
+	if err := controllerutil.SetControllerReference(controller, legacy, newTestReconciler().Scheme); err != nil {
+		t.Fatal(err)
+	}
+	return legacy
+}
+
+func TestMigrateClusterTrustedCA(t *testing.T) {
+	foo := newDefaultUpdateService()
+	foo.UID = "foo-uid"
+	foo.Status.SchemaVersion = clusterTrustedCASchemaVersion - 1
+	bar := newDefaultUpdateService()
+	bar.Name = "bar"
+	bar.UID = "bar-uid"
+	bar.Status.SchemaVersion = clusterTrustedCASchemaVersion - 1
+	legacy := newLegacyClusterTrustedCA(t, foo)
+	// the Deployments of earlier operator versions mount the shared ConfigMap
+	legacyDeployment := func(instance *cv1.UpdateService) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: nameDeployment(instance), Namespace: testNamespace},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Volumes: []corev1.Volume{{
+							Name: NameClusterTrustedCAVolume,
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: legacyClusterTrustedCAName},
+								},
+							},
+						}},
+					},
+				},
+			},
+		}
+	}
+	r := newTestReconciler(foo, bar, legacy, legacyDeployment(foo), legacyDeployment(bar))
+	resourcesOf := func(instance *cv1.UpdateService) *kubeResources {
+		t.Helper()
+		resources, err := newKubeResources(instance, testOperandImage, testNamespace, nil, newSecret(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resources
+	}
+	// migrate runs the migration, applies the Deployment which mounts the
+	// ConfigMap of instance, and then ensures the shared ConfigMap
+	migrate := func(instance *cv1.UpdateService) {
+		t.Helper()
+		resources := resourcesOf(instance)
+		if err := r.migrateClusterTrustedCA(context.TODO(), log, instance, resources); err != nil {
+			t.Fatal(err)
+		}
+		found := &corev1.ConfigMap{}
+		if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(legacy), found); err != nil {
+			t.Fatalf("the migration should not delete the shared ConfigMap the Deployment mounts: %v", err)
+		}
+		deployment := &appsv1.Deployment{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(instance), Namespace: testNamespace}, deployment); err != nil {
+			t.Fatal(err)
+		}
+		deployment.Spec.Template.Spec.Volumes[0].ConfigMap.Name = nameClusterTrustedCAConfig(instance)
+		if err := r.Client.Update(context.TODO(), deployment); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.ensureLegacyClusterTrustedCA(context.TODO(), log, instance, resources); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the controller releases the shared ConfigMap, which bar still mounts
+	migrate(foo)
+	found := &corev1.ConfigMap{}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(legacy), found); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, found.OwnerReferences, "deleting foo should not delete the ConfigMap bar mounts")
+
+	// the last UpdateService to migrate deletes it once its Deployment no
+	// longer mounts it
+	migrate(bar)
+	err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(legacy), found)
+	assert.True(t, apiErrors.IsNotFound(err), "the shared ConfigMap should be deleted, got %v", err)
+}
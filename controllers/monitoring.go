@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// Alert thresholds used when spec.alerts does not override them.
+const (
+	defaultPolicyEngineUnavailableFor = 5 * time.Minute
+	defaultGraphDataMaxAge            = 7 * 24 * time.Hour
+	defaultRouteNotAdmittedFor        = 10 * time.Minute
+)
+
+// monitoringAvailable reports whether the cluster serves the given
+// monitoring.coreos.com kind, which is only the case when the Prometheus
+// operator CRDs are installed.
+func monitoringAvailable(mapper meta.RESTMapper, kind string) (bool, error) {
+	_, err := mapper.RESTMapping(schema.GroupKind{Group: monitoringv1.SchemeGroupVersion.Group, Kind: kind}, monitoringv1.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ensureServiceMonitor has cluster monitoring scrape the operand metrics,
+// when the monitoring CRDs exist. Besides the ServiceMonitor, cluster
+// monitoring needs a Role to discover the scraped endpoints.
+func (r *UpdateServiceReconciler) ensureServiceMonitor(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	available, err := monitoringAvailable(r.Client.RESTMapper(), monitoringv1.ServiceMonitorsKind)
+	if err != nil {
+		r.handleErr(reqLogger, instance, "FindMonitoringFailed", err)
+		return err
+	}
+	if !available {
+		return nil
+	}
+
+	role := resources.prometheusRole
+	binding := resources.prometheusRoleBinding
+	monitor := resources.serviceMonitor
+	for _, obj := range []metav1.Object{role, binding, monitor} {
+		// Set UpdateService instance as the owner and controller
+		if err := controllerutil.SetControllerReference(instance, obj, r.Scheme); err != nil {
+			return err
+		}
+	}
+
+	if err := r.ensureRole(ctx, reqLogger, instance, role); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureRoleFailed", err)
+		return err
+	}
+	if err := r.ensureRoleBinding(ctx, reqLogger, instance, binding); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureRoleBindingFailed", err)
+		return err
+	}
+
+	found := &monitoringv1.ServiceMonitor{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: monitor.Name, Namespace: monitor.Namespace}, found)
+	if err != nil && apiErrors.IsNotFound(err) {
+		reqLogger.Info("Creating ServiceMonitor", "Kind", "ServiceMonitor", "Namespace", monitor.Namespace, "Name", monitor.Name)
+		if err = r.create(ctx, instance, monitor); err != nil {
+			r.handleErr(reqLogger, instance, "CreateServiceMonitorFailed", err)
+		}
+		return err
+	} else if err != nil {
+		r.handleErr(reqLogger, instance, "GetServiceMonitorFailed", err)
+		return err
+	}
+
+	// found existing resource; let's compare and update if needed
+	updated := found.DeepCopy()
+	if relabeled := setInstanceLabel(updated, instance); relabeled || !reflect.DeepEqual(found.Spec, monitor.Spec) {
+		reqLogger.Info("Updating ServiceMonitor", "Kind", "ServiceMonitor", "Namespace", monitor.Namespace, "Name", monitor.Name)
+		updated.Spec = monitor.Spec
+		if err = r.update(ctx, instance, updated); err != nil {
+			r.handleErr(reqLogger, instance, "UpdateServiceMonitorFailed", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *UpdateServiceReconciler) ensureRole(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, role *rbacv1.Role) error {
+	// Check if this Role already exists
+	found := &rbacv1.Role{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: role.Name, Namespace: role.Namespace}, found)
+	if err != nil && apiErrors.IsNotFound(err) {
+		reqLogger.Info("Creating Role", "Kind", "Role", "Namespace", role.Namespace, "Name", role.Name)
+		return r.create(ctx, instance, role)
+	} else if err != nil {
+		return err
+	}
+
+	// found existing Role; let's compare and update if needed
+	updated := found.DeepCopy()
+	if relabeled := setInstanceLabel(updated, instance); relabeled || !reflect.DeepEqual(found.Rules, role.Rules) {
+		reqLogger.Info("Updating Role", "Kind", "Role", "Namespace", role.Namespace, "Name", role.Name)
+		updated.Rules = role.Rules
+		return r.update(ctx, instance, updated)
+	}
+	return nil
+}
+
+func (r *UpdateServiceReconciler) ensureRoleBinding(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, binding *rbacv1.RoleBinding) error {
+	// Check if this RoleBinding already exists
+	found := &rbacv1.RoleBinding{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: binding.Name, Namespace: binding.Namespace}, found)
+	if err != nil && apiErrors.IsNotFound(err) {
+		reqLogger.Info("Creating RoleBinding", "Kind", "RoleBinding", "Namespace", binding.Namespace, "Name", binding.Name)
+		return r.create(ctx, instance, binding)
+	} else if err != nil {
+		return err
+	}
+
+	// roleRef is immutable, so a RoleBinding for another Role is replaced
+	if found.RoleRef != binding.RoleRef {
+		reqLogger.Info("Replacing RoleBinding", "Kind", "RoleBinding", "Namespace", binding.Namespace, "Name", binding.Name)
+		if err := r.delete(ctx, instance, found); err != nil {
+			return err
+		}
+		return r.create(ctx, instance, binding)
+	}
+
+	// found existing RoleBinding; let's compare and update if needed
+	updated := found.DeepCopy()
+	if relabeled := setInstanceLabel(updated, instance); relabeled || !reflect.DeepEqual(found.Subjects, binding.Subjects) {
+		reqLogger.Info("Updating RoleBinding", "Kind", "RoleBinding", "Namespace", binding.Namespace, "Name", binding.Name)
+		updated.Subjects = binding.Subjects
+		return r.update(ctx, instance, updated)
+	}
+	return nil
+}
+
+// ensurePrometheusRule reconciles the UpdateService alerts, when the
+// monitoring CRDs exist.
+func (r *UpdateServiceReconciler) ensurePrometheusRule(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	available, err := monitoringAvailable(r.Client.RESTMapper(), monitoringv1.PrometheusRuleKind)
+	if err != nil {
+		r.handleErr(reqLogger, instance, "FindMonitoringFailed", err)
+		return err
+	}
+	if !available {
+		return nil
+	}
+
+	rule := resources.prometheusRule
+	// Set UpdateService instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, rule, r.Scheme); err != nil {
+		return err
+	}
+
+	found := &monitoringv1.PrometheusRule{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: rule.Name, Namespace: rule.Namespace}, found)
+	if err != nil && apiErrors.IsNotFound(err) {
+		reqLogger.Info("Creating PrometheusRule", "Kind", "PrometheusRule", "Namespace", rule.Namespace, "Name", rule.Name)
+		if err = r.create(ctx, instance, rule); err != nil {
+			r.handleErr(reqLogger, instance, "CreatePrometheusRuleFailed", err)
+		}
+		return err
+	} else if err != nil {
+		r.handleErr(reqLogger, instance, "GetPrometheusRuleFailed", err)
+		return err
+	}
+
+	// found existing resource; let's compare and update if needed
+	updated := found.DeepCopy()
+	if relabeled := setInstanceLabel(updated, instance); relabeled || !reflect.DeepEqual(found.Spec, rule.Spec) {
+		reqLogger.Info("Updating PrometheusRule", "Kind", "PrometheusRule", "Namespace", rule.Namespace, "Name", rule.Name)
+		updated.Spec = rule.Spec
+		if err = r.update(ctx, instance, updated); err != nil {
+			r.handleErr(reqLogger, instance, "UpdatePrometheusRuleFailed", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func TestEnsureServiceMonitor(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		monitoring bool
+	}{
+		{name: "monitoring CRDs missing"},
+		{name: "monitoring CRDs installed", monitoring: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updateservice := newDefaultUpdateService()
+			mapper := meta.NewDefaultRESTMapper(nil)
+			if tc.monitoring {
+				mapper.Add(monitoringv1.SchemeGroupVersion.WithKind(monitoringv1.ServiceMonitorsKind), meta.RESTScopeNamespace)
+			}
+			r := newTestReconciler()
+			r.Client = newFakeClientBuilder().WithRESTMapper(mapper).
+				WithRuntimeObjects(updateservice).WithStatusSubresource(&cv1.UpdateService{}).Build()
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.ensureServiceMonitor(context.TODO(), log, updateservice, resources); err != nil {
+				t.Fatal(err)
+			}
+
+			monitor := &monitoringv1.ServiceMonitor{}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: nameServiceMonitor(updateservice), Namespace: updateservice.Namespace}, monitor)
+			if !tc.monitoring {
+				assert.True(t, apiErrors.IsNotFound(err), "expected no ServiceMonitor, got %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyOwnerReference(t, monitor.OwnerReferences[0], updateservice)
+			assert.Equal(t, []string{"foo-graph-builder", "foo-policy-engine"}, monitor.Spec.Selector.MatchExpressions[0].Values)
+			assert.Equal(t, "status-gb", monitor.Spec.Endpoints[0].Port)
+			assert.Equal(t, "status-pe", monitor.Spec.Endpoints[1].Port)
+
+			binding := &rbacv1.RoleBinding{}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: namePrometheusRole(updateservice), Namespace: updateservice.Namespace}, binding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "foo-prometheus", binding.RoleRef.Name)
+			assert.Equal(t, rbacv1.Subject{Kind: "ServiceAccount", Name: "prometheus-k8s", Namespace: "openshift-monitoring"}, binding.Subjects[0])
+			role := &rbacv1.Role{}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: binding.RoleRef.Name, Namespace: updateservice.Namespace}, role); err != nil {
+				t.Fatal(err)
+			}
+
+			// a changed ServiceMonitor is updated
+			monitor.Spec.Endpoints = nil
+			if err := r.Client.Update(context.TODO(), monitor); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.ensureServiceMonitor(context.TODO(), log, updateservice, resources); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: monitor.Name, Namespace: monitor.Namespace}, monitor); err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, monitor.Spec.Endpoints, 2)
+		})
+	}
+}
+
+func TestEnsurePrometheusRule(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(monitoringv1.SchemeGroupVersion.WithKind(monitoringv1.PrometheusRuleKind), meta.RESTScopeNamespace)
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRESTMapper(mapper).
+		WithRuntimeObjects(updateservice).WithStatusSubresource(&cv1.UpdateService{}).Build()
+
+	alerts := func() map[string]monitoringv1.Rule {
+		found := &monitoringv1.PrometheusRule{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: namePrometheusRule(updateservice), Namespace: updateservice.Namespace}, found)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifyOwnerReference(t, found.OwnerReferences[0], updateservice)
+		rules := map[string]monitoringv1.Rule{}
+		for _, rule := range found.Spec.Groups[0].Rules {
+			rules[rule.Alert] = rule
+		}
+		return rules
+	}
+	ensure := func() {
+		resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.ensurePrometheusRule(context.TODO(), log, updateservice, resources); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ensure()
+	rules := alerts()
+	assert.Len(t, rules, 4)
+	assert.Equal(t, monitoringv1.Duration("5m"), *rules["UpdateServicePolicyEngineUnavailable"].For)
+	assert.Contains(t, rules["UpdateServiceGraphDataStale"].Expr.StrVal, "> 604800")
+	assert.Contains(t, rules["UpdateServiceGraphBuilderScrapeFailing"].Expr.StrVal, "> 1800")
+
+	updateservice.Spec.ScrapeFailureThreshold = &metav1.Duration{Duration: time.Hour}
+	updateservice.Spec.Alerts = &cv1.AlertThresholds{
+		PolicyEngineUnavailableFor: &metav1.Duration{Duration: 15 * time.Minute},
+		GraphDataMaxAge:            &metav1.Duration{Duration: 48 * time.Hour},
+	}
+	ensure()
+	rules = alerts()
+	assert.Equal(t, monitoringv1.Duration("15m"), *rules["UpdateServicePolicyEngineUnavailable"].For)
+	assert.Contains(t, rules["UpdateServiceGraphDataStale"].Expr.StrVal, "> 172800")
+	assert.Contains(t, rules["UpdateServiceGraphBuilderScrapeFailing"].Expr.StrVal, "> 3600")
+	assert.Equal(t, monitoringv1.Duration("10m"), *rules["UpdateServiceRouteNotAdmitted"].For)
+}
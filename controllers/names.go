@@ -26,6 +26,27 @@ const (
 	namePullSecret = "pull-secret"
 	// ClusterCAMountDir is the mount path for the dir containing cluster CA
 	ClusterCAMountDir = "/etc/pki/ca-trust/extracted/cluster-ca/"
+	// operatorName is the value of the name label on the operator's pods
+	operatorName = "updateservice-operator"
+	// monitoringNamespace is the namespace of the cluster monitoring stack
+	monitoringNamespace = "openshift-monitoring"
+	// monitoringServiceAccount is the ServiceAccount of the cluster monitoring Prometheus
+	monitoringServiceAccount = "prometheus-k8s"
+	// GraphDataPodLabel marks the pods which resolve graph-data image tags to
+	// digests, the only pods the operator caches
+	GraphDataPodLabel = "updateservice.operator.openshift.io/graph-data-tag-digest"
+	// instanceNameLabel is set to the name of the UpdateService on the
+	// resources rendered for it, which makes up its inventory. ConsoleLinks
+	// are cluster-scoped, so they cannot be owned by the UpdateService, and
+	// carry instanceNamespaceLabel too.
+	instanceNameLabel      = "updateservice.operator.openshift.io/name"
+	instanceNamespaceLabel = "updateservice.operator.openshift.io/namespace"
+	// nameConsoleDashboard is the name of the console dashboard ConfigMap shared by all UpdateServices
+	nameConsoleDashboard = "grafana-dashboard-openshift-update-service"
+	// injectTrustedCABundleLabel asks the cluster network operator to inject the cluster-wide trusted CA bundle into a ConfigMap
+	injectTrustedCABundleLabel = "config.openshift.io/inject-trusted-cabundle"
+	// legacyClusterTrustedCAName is the name of the cluster CA ConfigMap which earlier operator versions shared between the UpdateServices of a namespace
+	legacyClusterTrustedCAName = "cluster-trusted-ca"
 )
 
 func nameDeployment(instance *cv1.UpdateService) string {
@@ -56,14 +77,36 @@ func namePolicyEngineRoute(instance *cv1.UpdateService) string {
 	return instance.Name + "-route"
 }
 
-func oldPolicyEngineRouteName(instance *cv1.UpdateService) string {
+// legacyPolicyEngineRouteName is the name of the Route of earlier operator
+// versions, which is migrated to namePolicyEngineRoute.
+func legacyPolicyEngineRouteName(instance *cv1.UpdateService) string {
 	return namePolicyEngineService(instance) + "-route"
 }
 
+func nameServiceMonitor(instance *cv1.UpdateService) string {
+	return instance.Name + "-monitor"
+}
+
+func namePrometheusRule(instance *cv1.UpdateService) string {
+	return instance.Name + "-alerts"
+}
+
+func namePrometheusRole(instance *cv1.UpdateService) string {
+	return instance.Name + "-prometheus"
+}
+
+func nameConsoleLink(instance *cv1.UpdateService) string {
+	return "updateservice-" + instance.Namespace + "-" + instance.Name
+}
+
 func nameAdditionalTrustedCA(instance *cv1.UpdateService) string {
 	return instance.Name + "-trusted-ca"
 }
 
+func nameClusterTrustedCAConfig(instance *cv1.UpdateService) string {
+	return instance.Name + "-" + NameClusterTrustedCAVolume
+}
+
 func namePullSecretCopy(instance *cv1.UpdateService) string {
 	return instance.Name + "-" + namePullSecret
 }
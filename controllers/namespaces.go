@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// ParseWatchNamespaces parses a comma-separated list of the namespaces whose
+// UpdateServices are reconciled, as OLM sets it from the target namespaces of
+// the OperatorGroup. An empty list watches all namespaces.
+func ParseWatchNamespaces(value string) []string {
+	namespaces := sets.New[string]()
+	for _, ns := range strings.Split(value, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces.Insert(ns)
+		}
+	}
+	if namespaces.Len() == 0 {
+		return []string{cache.AllNamespaces}
+	}
+	return sets.List(namespaces)
+}
+
+// watchesAllNamespaces reports whether namespaces covers all namespaces.
+func watchesAllNamespaces(namespaces []string) bool {
+	for _, ns := range namespaces {
+		if ns == cache.AllNamespaces {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheOptions returns the cache options of an operator running in
+// operatorNamespace and reconciling the UpdateServices of watchNamespaces.
+// Beyond the UpdateServices and the graph-data pods, only the resources in the
+// inventory of an UpdateService are cached in the watched namespaces, so that
+// watching all namespaces does not cache all ConfigMaps and Secrets of the
+// cluster. The ConfigMaps referenced by an UpdateService, such as its
+// signature public key, are read uncached. The operator's namespace is cached
+// in full, as are the Secrets in openshift-config; its ConfigMaps are watched
+// for their metadata only, see SetupWithManager.
+func CacheOptions(operatorNamespace string, watchNamespaces []string) cache.Options {
+	inventoryLabel, err := labels.NewRequirement(instanceNameLabel, selection.Exists, nil)
+	if err != nil {
+		panic(err)
+	}
+	inventory := labels.NewSelector().Add(*inventoryLabel)
+	defaultNamespaces := map[string]cache.Config{
+		operatorNamespace:        {},
+		OpenshiftConfigNamespace: {},
+		// only the console dashboard the operator publishes
+		ConsoleDashboardNamespace: {
+			LabelSelector: labels.SelectorFromSet(labels.Set{ConsoleDashboardLabel: "true"}),
+		},
+	}
+	updateServices := map[string]cache.Config{}
+	pods := map[string]cache.Config{}
+	configMaps := map[string]cache.Config{
+		operatorNamespace: {LabelSelector: labels.Everything()},
+		ConsoleDashboardNamespace: {
+			LabelSelector: labels.SelectorFromSet(labels.Set{ConsoleDashboardLabel: "true"}),
+		},
+	}
+	for _, ns := range watchNamespaces {
+		if _, ok := defaultNamespaces[ns]; !ok {
+			defaultNamespaces[ns] = cache.Config{LabelSelector: inventory}
+		}
+		updateServices[ns] = cache.Config{LabelSelector: labels.Everything()}
+		pods[ns] = cache.Config{}
+		if _, ok := configMaps[ns]; !ok {
+			configMaps[ns] = cache.Config{LabelSelector: inventory}
+		}
+	}
+	return cache.Options{
+		DefaultNamespaces: defaultNamespaces,
+		ByObject: map[client.Object]cache.ByObject{
+			&cv1.UpdateService{}: {Namespaces: updateServices},
+			// only the graph-data pods, not the operand's
+			&corev1.Pod{}: {
+				Namespaces: pods,
+				Label:      labels.SelectorFromSet(labels.Set{GraphDataPodLabel: "true"}),
+			},
+			// only the operand inventory in the watched namespaces; the
+			// ConfigMaps in openshift-config are read uncached, and the
+			// controller watches their metadata only
+			&corev1.ConfigMap{}: {Namespaces: configMaps},
+		},
+	}
+}
+
+// watchedNamespaces returns the namespaces whose UpdateServices are
+// reconciled, which default to the operator's.
+func (r *UpdateServiceReconciler) watchedNamespaces() []string {
+	if len(r.WatchNamespaces) == 0 {
+		return []string{r.OperatorNamespace}
+	}
+	return r.WatchNamespaces
+}
+
+// watches reports whether the UpdateServices in namespace are reconciled.
+func (r *UpdateServiceReconciler) watches(namespace string) bool {
+	namespaces := r.watchedNamespaces()
+	return watchesAllNamespaces(namespaces) || slices.Contains(namespaces, namespace)
+}
+
+// listUpdateServices lists the UpdateServices in namespaces.
+func listUpdateServices(ctx context.Context, c client.Reader, namespaces []string) ([]cv1.UpdateService, error) {
+	if watchesAllNamespaces(namespaces) {
+		namespaces = []string{cache.AllNamespaces}
+	}
+	var updateservices []cv1.UpdateService
+	for _, ns := range namespaces {
+		list := &cv1.UpdateServiceList{}
+		if err := c.List(ctx, list, client.InNamespace(ns)); err != nil {
+			return nil, err
+		}
+		updateservices = append(updateservices, list.Items...)
+	}
+	return updateservices, nil
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/yaml"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func TestParseWatchNamespaces(t *testing.T) {
+	for value, expected := range map[string][]string{
+		"":                            {cache.AllNamespaces},
+		" , ":                         {cache.AllNamespaces},
+		"tenant-a":                    {"tenant-a"},
+		"tenant-b, tenant-a,tenant-b": {"tenant-a", "tenant-b"},
+	} {
+		assert.Equal(t, expected, ParseWatchNamespaces(value), value)
+	}
+}
+
+func TestCacheOptions(t *testing.T) {
+	inventory := labels.Set{instanceNameLabel: testName}
+	byObject := func(options cache.Options, obj interface{}) cache.ByObject {
+		t.Helper()
+		for o, b := range options.ByObject {
+			if assert.ObjectsAreEqual(o, obj) {
+				return b
+			}
+		}
+		t.Fatalf("no cache options for %T", obj)
+		return cache.ByObject{}
+	}
+
+	for name, test := range map[string]struct {
+		watchNamespaces []string
+		tenant          string
+	}{
+		"list": {watchNamespaces: []string{"bar", "tenant"}, tenant: "tenant"},
+		"all":  {watchNamespaces: []string{cache.AllNamespaces}, tenant: cache.AllNamespaces},
+	} {
+		t.Run(name, func(t *testing.T) {
+			options := CacheOptions("bar", test.watchNamespaces)
+
+			// the operator's namespace is cached in full
+			assert.Equal(t, cache.Config{}, options.DefaultNamespaces["bar"])
+			assert.Equal(t, cache.Config{}, options.DefaultNamespaces[OpenshiftConfigNamespace])
+
+			// the other watched namespaces only for the inventories
+			tenant := options.DefaultNamespaces[test.tenant].LabelSelector
+			if assert.NotNil(t, tenant) {
+				assert.True(t, tenant.Matches(inventory))
+				assert.False(t, tenant.Matches(labels.Set{}))
+			}
+			assert.Equal(t, labels.Everything(), byObject(options, &cv1.UpdateService{}).Namespaces[test.tenant].LabelSelector)
+			assert.Contains(t, byObject(options, &corev1.Pod{}).Namespaces, test.tenant)
+			configMaps := byObject(options, &corev1.ConfigMap{}).Namespaces
+			if assert.Contains(t, configMaps, test.tenant) {
+				// not all ConfigMaps of the watched namespaces
+				tenant := configMaps[test.tenant].LabelSelector
+				assert.True(t, tenant.Matches(inventory))
+				assert.False(t, tenant.Matches(labels.Set{}))
+			}
+			assert.Equal(t, labels.Everything(), configMaps["bar"].LabelSelector)
+			assert.NotContains(t, configMaps, OpenshiftConfigNamespace)
+		})
+	}
+}
+
+func TestReconcileWatchedNamespaces(t *testing.T) {
+	tenant := newDefaultUpdateService()
+	tenant.Namespace = "tenant"
+	unwatched := newDefaultUpdateService()
+	unwatched.Namespace = "unwatched"
+	r := newTestReconciler(tenant, unwatched, newSecret())
+	r.WatchNamespaces = []string{testNamespace, tenant.Namespace}
+
+	for _, instance := range []*cv1.UpdateService{tenant, unwatched} {
+		if _, err := r.Reconcile(context.TODO(), newRequest(instance)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(unwatched), Namespace: unwatched.Namespace}, &appsv1.Deployment{})
+	assert.True(t, apiErrors.IsNotFound(err), "an UpdateService outside of the watched namespaces should be ignored, got %v", err)
+
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(tenant), Namespace: tenant.Namespace}, &appsv1.Deployment{}); err != nil {
+		t.Fatal(err)
+	}
+	pullSecret := &corev1.Secret{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: namePullSecretCopy(tenant), Namespace: tenant.Namespace}, pullSecret); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, newSecret().Data, pullSecret.Data)
+
+	// the operator reaches the operand from its own namespace
+	policy := &networkingv1.NetworkPolicy{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: tenant.Name, Namespace: tenant.Namespace}, policy); err != nil {
+		t.Fatal(err)
+	}
+	operator := policy.Spec.Ingress[1].From[0]
+	if assert.NotNil(t, operator.NamespaceSelector) {
+		assert.Equal(t, map[string]string{corev1.LabelMetadataName: r.OperatorNamespace}, operator.NamespaceSelector.MatchLabels)
+	}
+}
+
+// TestWatchNamespaceRBAC checks that the RBAC of the watched namespaces grants
+// the permissions which the operator has in its own namespace.
+func TestWatchNamespaceRBAC(t *testing.T) {
+	data, err := os.ReadFile("../config/rbac/role.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var role *rbacv1.Role
+	for _, doc := range strings.Split(string(data), "---\n") {
+		r := &rbacv1.Role{}
+		if err := yaml.Unmarshal([]byte(doc), r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Kind == "Role" && r.Namespace == "openshift-update-service" {
+			role = r
+		}
+	}
+	if role == nil {
+		t.Fatal("no Role in the operator's namespace")
+	}
+
+	data, err = os.ReadFile("../config/rbac/watch-namespace/operand_role.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	operand := &rbacv1.ClusterRole{}
+	if err := yaml.Unmarshal(data, operand); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, role.Rules, operand.Rules, "config/rbac/watch-namespace/operand_role.yaml should have the rules of the Role in config/rbac/role.yaml")
+}
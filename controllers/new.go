@@ -6,20 +6,25 @@ import (
 	"fmt"
 	"net"
 	"net/url"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
+	apicfgv1 "github.com/openshift/api/config/v1"
 	routev1 "github.com/openshift/api/route/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	cv1 "github.com/openshift/cincinnati-operator/api/v1"
 )
@@ -36,6 +41,18 @@ const (
 	// the Pod will be replaced whenever the content of the ConfigMap changes.
 	EnvConfigHashAnnotation string = "updateservice.operator.openshift.io/env-config-hash"
 
+	// GraphDataImageAnnotation is the key for an annotation storing the
+	// resolved graph-data image on the operand Pod. Storing the annotation
+	// ensures that the Pod will be replaced whenever a by-tag graph-data image
+	// resolves to a new digest.
+	GraphDataImageAnnotation string = "updateservice.operator.openshift.io/graph-data-image"
+
+	// GraphDataPinAnnotation is the key for an UpdateService annotation which
+	// pins the operand to a graph-data image digest from
+	// status.graphDataHistory, either as a sha256:... digest or as a by-digest
+	// pull spec. Remove the annotation to resume serving the latest digest.
+	GraphDataPinAnnotation string = "updateservice.operator.openshift.io/pin-graph-data-image"
+
 	// DescriptionAnnotation is the key for an annotation used for describing specific behaviour of given object.
 	//  https://kubernetes.io/docs/reference/labels-annotations-taints/#description
 	DescriptionAnnotation = "kubernetes.io/description"
@@ -92,17 +109,24 @@ type kubeResources struct {
 	graphBuilderService      *corev1.Service
 	policyEngineService      *corev1.Service
 	policyEngineRoute        *routev1.Route
-	policyEngineOldRoute     *routev1.Route
 	networkPolicy            *networkingv1.NetworkPolicy
+	serviceMonitor           *monitoringv1.ServiceMonitor
+	prometheusRule           *monitoringv1.PrometheusRule
+	prometheusRole           *rbacv1.Role
+	prometheusRoleBinding    *rbacv1.RoleBinding
 	trustedCAConfig          *corev1.ConfigMap
 	trustedClusterCAConfig   *corev1.ConfigMap
 	pullSecret               *corev1.Secret
 	volumes                  []corev1.Volume
 	graphBuilderVolumeMounts []corev1.VolumeMount
+	proxy                    proxySettings
 }
 
-func newKubeResources(instance *cv1.UpdateService, image string, pullSecret *corev1.Secret, caConfigMap *corev1.ConfigMap, clusterCA *corev1.ConfigMap) (*kubeResources, error) {
-	k := kubeResources{}
+// newKubeResources renders the resources of the UpdateService. The operator
+// runs in operatorNamespace, which may differ from the one of the UpdateService.
+// proxy is the cluster-wide Proxy, which is nil when there is none.
+func newKubeResources(instance *cv1.UpdateService, image string, operatorNamespace string, proxy *apicfgv1.Proxy, pullSecret *corev1.Secret, caConfigMap *corev1.ConfigMap) (*kubeResources, error) {
+	k := kubeResources{proxy: newProxySettings(instance, proxy)}
 
 	gbConfig, err := k.newGraphBuilderConfig(instance)
 	if err != nil {
@@ -123,7 +147,7 @@ func newKubeResources(instance *cv1.UpdateService, image string, pullSecret *cor
 		return nil, err
 	}
 	k.trustedCAConfig = k.newTrustedCAConfig(instance, caConfigMap)
-	k.trustedClusterCAConfig = newTrustedClusterCAConfig(instance, clusterCA)
+	k.trustedClusterCAConfig = newTrustedClusterCAConfig(instance, k.proxy)
 	k.pullSecret = k.newPullSecret(instance, pullSecret)
 	k.envConfigHash = envConfigHash
 	k.podDisruptionBudget = k.newPodDisruptionBudget(instance)
@@ -135,12 +159,52 @@ func newKubeResources(instance *cv1.UpdateService, image string, pullSecret *cor
 	k.deployment = k.newDeployment(instance)
 	k.graphBuilderService = k.newGraphBuilderService(instance)
 	k.policyEngineService = k.newPolicyEngineService(instance)
-	k.policyEngineRoute = k.newPolicyEngineRoute(instance)
-	k.policyEngineOldRoute = k.oldPolicyEngineRoute(instance)
-	k.networkPolicy = k.newNetworkPolicy(instance)
+	if instance.Spec.Exposure != cv1.ExposureNone {
+		k.policyEngineRoute = k.newPolicyEngineRoute(instance)
+	}
+	k.networkPolicy = k.newNetworkPolicy(instance, operatorNamespace)
+	k.serviceMonitor = k.newServiceMonitor(instance)
+	k.prometheusRule = k.newPrometheusRule(instance)
+	k.prometheusRole = k.newPrometheusRole(instance)
+	k.prometheusRoleBinding = k.newPrometheusRoleBinding(instance)
+	for _, obj := range k.objects() {
+		setInstanceLabel(obj, instance)
+	}
 	return &k, nil
 }
 
+// objects returns the rendered resources, which make up the inventory of the
+// UpdateService. The CAs and the pull secret are only rendered when they are
+// configured.
+func (k *kubeResources) objects() []client.Object {
+	objs := []client.Object{
+		k.envConfig,
+		k.graphBuilderConfig,
+		k.podDisruptionBudget,
+		k.deployment,
+		k.graphBuilderService,
+		k.policyEngineService,
+		k.networkPolicy,
+		k.serviceMonitor,
+		k.prometheusRule,
+		k.prometheusRole,
+		k.prometheusRoleBinding,
+	}
+	if k.policyEngineRoute != nil {
+		objs = append(objs, k.policyEngineRoute)
+	}
+	if k.trustedCAConfig != nil {
+		objs = append(objs, k.trustedCAConfig)
+	}
+	if k.trustedClusterCAConfig != nil {
+		objs = append(objs, k.trustedClusterCAConfig)
+	}
+	if k.pullSecret != nil {
+		objs = append(objs, k.pullSecret)
+	}
+	return objs
+}
+
 func (k *kubeResources) newPodDisruptionBudget(instance *cv1.UpdateService) *policyv1.PodDisruptionBudget {
 	minAvailable := getMinAvailablePBD(instance)
 	return &policyv1.PodDisruptionBudget{
@@ -275,38 +339,7 @@ func (k *kubeResources) newPolicyEngineRoute(instance *cv1.UpdateService) *route
 	}
 }
 
-func (k *kubeResources) oldPolicyEngineRoute(instance *cv1.UpdateService) *routev1.Route {
-	name := oldPolicyEngineRouteName(instance)
-	return &routev1.Route{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: instance.Namespace,
-			Annotations: map[string]string{
-				DescriptionAnnotation: "It exposes views of the update graph by applying a set of filters " +
-					"which are defined within the particular Policy Engine instance. " +
-					"See https://github.com/openshift/cincinnati/blob/master/docs/design/cincinnati.md#policy-engine for more details",
-			},
-			Labels: map[string]string{
-				"app": nameDeployment(instance),
-			},
-		},
-		Spec: routev1.RouteSpec{
-			Port: &routev1.RoutePort{
-				TargetPort: intstr.FromString("policy-engine"),
-			},
-			To: routev1.RouteTargetReference{
-				Kind: "Service",
-				Name: namePolicyEngineService(instance),
-			},
-			TLS: &routev1.TLSConfig{
-				Termination:                   routev1.TLSTerminationEdge,
-				InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyNone,
-			},
-		},
-	}
-}
-
-func egressPorts(releases string) []int32 {
+func egressPorts(releases string, proxy proxySettings) []int32 {
 	seen := map[int32]bool{}
 	var ports []int32
 	add := func(url string, addDefaultOnErr bool) {
@@ -326,11 +359,11 @@ func egressPorts(releases string) []int32 {
 
 	registry := strings.SplitN(releases, "/", 2)[0]
 
-	registryNoProxy := noProxy(registry)
+	registryNoProxy := noProxy(registry, proxy.noProxy)
 
 	if namespaceFromInternalHost(registry) == "" && !registryNoProxy {
-		for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY"} {
-			if v := os.Getenv(env); v != "" {
+		for _, v := range []string{proxy.httpProxy, proxy.httpsProxy} {
+			if v != "" {
 				add(v, false)
 			}
 		}
@@ -344,9 +377,8 @@ func egressPorts(releases string) []int32 {
 	return ports
 }
 
-func noProxy(registry string) bool {
-	noProxyEnv := os.Getenv("NO_PROXY")
-	if noProxyEnv == "" {
+func noProxy(registry string, noProxyList string) bool {
+	if noProxyList == "" {
 		return false
 	}
 	// ref. https://docs.redhat.com/en/documentation/openshift_container_platform/3.11/html/configuring_clusters/install-config-http-proxies
@@ -354,7 +386,7 @@ func noProxy(registry string) bool {
 	if i := strings.IndexAny(registry, ":"); i > -1 {
 		registry = registry[:i]
 	}
-	for _, s := range strings.Split(noProxyEnv, ",") {
+	for _, s := range strings.Split(noProxyList, ",") {
 		trimmed := strings.TrimSpace(s)
 		if trimmed == "*" {
 			return true
@@ -422,8 +454,8 @@ func namespaceFromInternalHost(host string) string {
 	return parts[len(parts)-1]
 }
 
-func (k *kubeResources) newNetworkPolicy(instance *cv1.UpdateService) *networkingv1.NetworkPolicy {
-	ports := egressPorts(instance.Spec.Releases)
+func (k *kubeResources) newNetworkPolicy(instance *cv1.UpdateService, operatorNamespace string) *networkingv1.NetworkPolicy {
+	ports := egressPorts(instance.Spec.Releases, k.proxy)
 	egressPolicyPorts := make([]networkingv1.NetworkPolicyPort, len(ports))
 	for i, p := range ports {
 		egressPolicyPorts[i] = networkingv1.NetworkPolicyPort{
@@ -454,8 +486,10 @@ func (k *kubeResources) newNetworkPolicy(instance *cv1.UpdateService) *networkin
 			Namespace: instance.Namespace,
 			Annotations: map[string]string{
 				DescriptionAnnotation: egressDescription +
-					"It allows ingress from the router, to support serving policy-engine responses. " +
-					"All other ingress is blocked, including, for now, metrics scraping.",
+					"It allows ingress from the router, to support serving policy-engine responses, " +
+					"from the operator, to check the served update graphs and the graph-builder status, " +
+					"and from cluster monitoring, to scrape the graph-builder and policy-engine metrics. " +
+					"All other ingress is blocked.",
 			},
 			Labels: map[string]string{
 				"app": instance.Name,
@@ -480,6 +514,44 @@ func (k *kubeResources) newNetworkPolicy(instance *cv1.UpdateService) *networkin
 					Protocol: corev1ProtocolPtr(corev1.ProtocolTCP),
 					Port:     intOrStringPtr(intstr.FromString("policy-engine")),
 				}},
+			}, {
+				// Traffic from the operator, checking the served graphs and
+				// the graph-builder status
+				From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							corev1.LabelMetadataName: operatorNamespace,
+						},
+					},
+					PodSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"name": operatorName,
+						},
+					},
+				}},
+				Ports: []networkingv1.NetworkPolicyPort{{
+					Protocol: corev1ProtocolPtr(corev1.ProtocolTCP),
+					Port:     intOrStringPtr(intstr.FromString("policy-engine")),
+				}, {
+					Protocol: corev1ProtocolPtr(corev1.ProtocolTCP),
+					Port:     intOrStringPtr(intstr.FromString("status-gb")),
+				}},
+			}, {
+				// Traffic from cluster monitoring, scraping metrics
+				From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							corev1.LabelMetadataName: monitoringNamespace,
+						},
+					},
+				}},
+				Ports: []networkingv1.NetworkPolicyPort{{
+					Protocol: corev1ProtocolPtr(corev1.ProtocolTCP),
+					Port:     intOrStringPtr(intstr.FromString("status-gb")),
+				}, {
+					Protocol: corev1ProtocolPtr(corev1.ProtocolTCP),
+					Port:     intOrStringPtr(intstr.FromString("status-pe")),
+				}},
 			}},
 			Egress: []networkingv1.NetworkPolicyEgressRule{
 				// TCP access only to the necessary ports, for registry access, possibly via proxies
@@ -508,6 +580,189 @@ func (k *kubeResources) newNetworkPolicy(instance *cv1.UpdateService) *networkin
 	}
 }
 
+func (k *kubeResources) newServiceMonitor(instance *cv1.UpdateService) *monitoringv1.ServiceMonitor {
+	name := nameServiceMonitor(instance)
+	return &monitoringv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: instance.Namespace,
+			Annotations: map[string]string{
+				DescriptionAnnotation: "This ServiceMonitor has cluster monitoring scrape the graph-builder and policy-engine " +
+					"metrics from their status ports.",
+			},
+			Labels: map[string]string{
+				"app": instance.Name,
+			},
+		},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "app",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{nameGraphBuilderService(instance), namePolicyEngineService(instance)},
+				}},
+			},
+			NamespaceSelector: monitoringv1.NamespaceSelector{
+				MatchNames: []string{instance.Namespace},
+			},
+			Endpoints: []monitoringv1.Endpoint{{
+				Port:   "status-gb",
+				Path:   "/metrics",
+				Scheme: "http",
+			}, {
+				Port:   "status-pe",
+				Path:   "/metrics",
+				Scheme: "http",
+			}},
+		},
+	}
+}
+
+func (k *kubeResources) newPrometheusRule(instance *cv1.UpdateService) *monitoringv1.PrometheusRule {
+	policyEngineUnavailableFor := defaultPolicyEngineUnavailableFor
+	graphDataMaxAge := defaultGraphDataMaxAge
+	routeNotAdmittedFor := defaultRouteNotAdmittedFor
+	if alerts := instance.Spec.Alerts; alerts != nil {
+		if alerts.PolicyEngineUnavailableFor != nil {
+			policyEngineUnavailableFor = alerts.PolicyEngineUnavailableFor.Duration
+		}
+		if alerts.GraphDataMaxAge != nil {
+			graphDataMaxAge = alerts.GraphDataMaxAge.Duration
+		}
+		if alerts.RouteNotAdmittedFor != nil {
+			routeNotAdmittedFor = alerts.RouteNotAdmittedFor.Duration
+		}
+	}
+	scrapeFailureThreshold := defaultScrapeFailureThreshold
+	if instance.Spec.ScrapeFailureThreshold != nil {
+		scrapeFailureThreshold = instance.Spec.ScrapeFailureThreshold.Duration
+	}
+
+	// the operator's metrics label the UpdateService namespace with
+	// namespaceLabel
+	instanceSelector := fmt.Sprintf(`%s=%q, name=%q`, namespaceLabel, instance.Namespace, instance.Name)
+	graphBuilderSelector := fmt.Sprintf(`namespace=%q, service=%q`, instance.Namespace, nameGraphBuilderService(instance))
+	labels := map[string]string{
+		"severity": "warning",
+	}
+	return &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namePrometheusRule(instance),
+			Namespace: instance.Namespace,
+			Annotations: map[string]string{
+				DescriptionAnnotation: "This PrometheusRule alerts when the UpdateService cannot serve update graphs, " +
+					"or serves outdated ones. The thresholds can be changed in the UpdateService spec.",
+			},
+			Labels: map[string]string{
+				"app": instance.Name,
+			},
+		},
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{{
+				Name: "updateservice-" + instance.Name,
+				Rules: []monitoringv1.Rule{{
+					Alert: "UpdateServicePolicyEngineUnavailable",
+					Expr: intstr.FromString(fmt.Sprintf(`kube_deployment_status_replicas_available{namespace=%q, deployment=%q} == 0`,
+						instance.Namespace, nameDeployment(instance))),
+					For:    prometheusDuration(policyEngineUnavailableFor),
+					Labels: labels,
+					Annotations: map[string]string{
+						"summary": "The UpdateService policy engine has no ready replicas.",
+						"description": fmt.Sprintf("UpdateService %s/%s has had no ready replicas for %s, so clusters cannot retrieve update graphs from it.",
+							instance.Namespace, instance.Name, model.Duration(policyEngineUnavailableFor)),
+					},
+				}, {
+					Alert: "UpdateServiceGraphBuilderScrapeFailing",
+					Expr: intstr.FromString(fmt.Sprintf(`time() - max by (namespace, service) (cincinnati_gb_graph_last_successful_refresh_timestamp{%s}) > %d
+and
+time() - max by (namespace, service) (process_start_time_seconds{%s}) > %d`,
+						graphBuilderSelector, int64(scrapeFailureThreshold.Seconds()), graphBuilderSelector, int64(scrapeFailureThreshold.Seconds()))),
+					Labels: labels,
+					Annotations: map[string]string{
+						"summary": "The UpdateService graph-builder is failing to scrape the release repository.",
+						"description": fmt.Sprintf("The graph-builder of UpdateService %s/%s has not scraped %s successfully for more than %s, so the update graph it serves is not current.",
+							instance.Namespace, instance.Name, instance.Spec.Releases, model.Duration(scrapeFailureThreshold)),
+					},
+				}, {
+					Alert: "UpdateServiceGraphDataStale",
+					Expr: intstr.FromString(fmt.Sprintf(`time() - updateservice_graph_data_digest_change_timestamp_seconds{%s} > %d`,
+						instanceSelector, int64(graphDataMaxAge.Seconds()))),
+					Labels: labels,
+					Annotations: map[string]string{
+						"summary": "The UpdateService graph-data image has not changed for a long time.",
+						"description": fmt.Sprintf("The graph-data image %s of UpdateService %s/%s has resolved to the same digest for more than %s, so update recommendations may be outdated.",
+							instance.Spec.GraphDataImage, instance.Namespace, instance.Name, model.Duration(graphDataMaxAge)),
+					},
+				}, {
+					Alert:  "UpdateServiceRouteNotAdmitted",
+					Expr:   intstr.FromString(fmt.Sprintf(`updateservice_policy_engine_uri_set{%s} == 0`, instanceSelector)),
+					For:    prometheusDuration(routeNotAdmittedFor),
+					Labels: labels,
+					Annotations: map[string]string{
+						"summary": "The UpdateService Route has no admitted ingress.",
+						"description": fmt.Sprintf("Route %s/%s has had no admitted ingress for %s, so the UpdateService policy engine is not reachable from outside the cluster.",
+							instance.Namespace, namePolicyEngineRoute(instance), model.Duration(routeNotAdmittedFor)),
+					},
+				}},
+			}},
+		},
+	}
+}
+
+// prometheusDuration formats d the way Prometheus does, such as 1h30m.
+func prometheusDuration(d time.Duration) *monitoringv1.Duration {
+	duration := monitoringv1.Duration(model.Duration(d).String())
+	return &duration
+}
+
+func (k *kubeResources) newPrometheusRole(instance *cv1.UpdateService) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namePrometheusRole(instance),
+			Namespace: instance.Namespace,
+			Annotations: map[string]string{
+				DescriptionAnnotation: "This Role allows cluster monitoring to discover the graph-builder and policy-engine " +
+					"metrics endpoints selected by the ServiceMonitor.",
+			},
+			Labels: map[string]string{
+				"app": instance.Name,
+			},
+		},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"services", "endpoints", "pods"},
+			Verbs:     []string{"get", "list", "watch"},
+		}},
+	}
+}
+
+func (k *kubeResources) newPrometheusRoleBinding(instance *cv1.UpdateService) *rbacv1.RoleBinding {
+	name := namePrometheusRole(instance)
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: instance.Namespace,
+			Annotations: map[string]string{
+				DescriptionAnnotation: "This RoleBinding grants the cluster monitoring Prometheus the Role to discover " +
+					"the graph-builder and policy-engine metrics endpoints.",
+			},
+			Labels: map[string]string{
+				"app": instance.Name,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      monitoringServiceAccount,
+			Namespace: monitoringNamespace,
+		}},
+	}
+}
+
 func corev1ProtocolPtr(proto corev1.Protocol) *corev1.Protocol { return &proto }
 
 func intOrStringPtr(intOrString intstr.IntOrString) *intstr.IntOrString { return &intOrString }
@@ -681,7 +936,7 @@ func (k *kubeResources) newVolumes(instance *cv1.UpdateService) []corev1.Volume
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					DefaultMode: &mode,
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: NameClusterTrustedCAVolume,
+						Name: nameClusterTrustedCAConfig(instance),
 					},
 				},
 			},
@@ -722,28 +977,24 @@ func (k *kubeResources) newTrustedCAConfig(instance *cv1.UpdateService, cm *core
 	}
 }
 
-func newTrustedClusterCAConfig(instance *cv1.UpdateService, clusterCA *corev1.ConfigMap) *corev1.ConfigMap {
-
-	// check if the proxy variables are set by olm
-	httpProxy := os.Getenv("HTTP_PROXY")
-	httpsProxy := os.Getenv("HTTPS_PROXY")
-	noProxy := os.Getenv("NO_PROXY")
-
-	if httpProxy == "" && httpsProxy == "" && noProxy == "" {
-		// cluster wide proxy is not set, so dont create configmap
+// newTrustedClusterCAConfig returns the ConfigMap into which the cluster-wide
+// trusted CA bundle is injected, when the graph builder uses a proxy or the
+// cluster-wide proxy trusts additional CAs. Every UpdateService has its own, so
+// that it is not garbage-collected with another one.
+func newTrustedClusterCAConfig(instance *cv1.UpdateService, proxy proxySettings) *corev1.ConfigMap {
+	if !proxy.enabled() && !proxy.trustedCA {
 		return nil
 	}
 
-	if clusterCA != nil {
-		return clusterCA
-	}
-
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        NameClusterTrustedCAVolume,
-			Namespace:   instance.Namespace,
-			Labels:      map[string]string{"config.openshift.io/inject-trusted-cabundle": "true"},
-			Annotations: map[string]string{"release.openshift.io/create-only": "true"},
+			Name:      nameClusterTrustedCAConfig(instance),
+			Namespace: instance.Namespace,
+			Labels:    map[string]string{injectTrustedCABundleLabel: "true"},
+			Annotations: map[string]string{
+				DescriptionAnnotation: "The cluster-wide trusted CA bundle is injected into this ConfigMap, " +
+					"for the graph builder to access the release repository through the cluster-wide proxy.",
+			},
 		},
 	}
 }
@@ -768,8 +1019,8 @@ func (k *kubeResources) newGraphBuilderContainer(instance *cv1.UpdateService, im
 		newCMEnvVar("RUST_BACKTRACE", "gb.rust_backtrace", nameEnvConfig(instance)),
 	}
 
-	// get the cluster proxy variables, and append to ENV var if set
-	if httpProxy := os.Getenv("HTTP_PROXY"); httpProxy != "" {
+	// append the proxy variables to ENV var if set
+	if httpProxy := k.proxy.httpProxy; httpProxy != "" {
 		gbENV = append(gbENV,
 			corev1.EnvVar{
 				Name:  "HTTP_PROXY",
@@ -777,7 +1028,7 @@ func (k *kubeResources) newGraphBuilderContainer(instance *cv1.UpdateService, im
 			},
 		)
 	}
-	if httpsProxy := os.Getenv("HTTPS_PROXY"); httpsProxy != "" {
+	if httpsProxy := k.proxy.httpsProxy; httpsProxy != "" {
 		gbENV = append(gbENV,
 			corev1.EnvVar{
 				Name:  "HTTPS_PROXY",
@@ -785,7 +1036,7 @@ func (k *kubeResources) newGraphBuilderContainer(instance *cv1.UpdateService, im
 			},
 		)
 	}
-	if noProxy := os.Getenv("NO_PROXY"); noProxy != "" {
+	if noProxy := k.proxy.noProxy; noProxy != "" {
 		gbENV = append(gbENV,
 			corev1.EnvVar{
 				Name:  "NO_PROXY",
@@ -817,17 +1068,8 @@ func (k *kubeResources) newGraphBuilderContainer(instance *cv1.UpdateService, im
 				Protocol:      corev1.ProtocolTCP,
 			},
 		},
-		Env: gbENV,
-		Resources: corev1.ResourceRequirements{
-			Limits: corev1.ResourceList{
-				corev1.ResourceCPU:    *resource.NewMilliQuantity(750, resource.DecimalSI),
-				corev1.ResourceMemory: *resource.NewQuantity(512*1024*1024, resource.BinarySI),
-			},
-			Requests: corev1.ResourceList{
-				corev1.ResourceCPU:    *resource.NewMilliQuantity(150, resource.DecimalSI),
-				corev1.ResourceMemory: *resource.NewQuantity(64*1024*1024, resource.BinarySI),
-			},
-		},
+		Env:          gbENV,
+		Resources:    operandResources(instance, func(r *cv1.OperandResources) *corev1.ResourceRequirements { return r.GraphBuilder }),
 		VolumeMounts: k.graphBuilderVolumeMounts,
 		LivenessProbe: &corev1.Probe{
 			FailureThreshold:    3,
@@ -943,16 +1185,7 @@ func (k *kubeResources) newPolicyEngineContainer(instance *cv1.UpdateService, im
 			newCMEnvVar("PE_MANDATORY_CLIENT_PARAMETERS", "pe.mandatory_client_parameters", envConfigName),
 			newCMEnvVar("RUST_BACKTRACE", "pe.rust_backtrace", envConfigName),
 		},
-		Resources: corev1.ResourceRequirements{
-			Limits: corev1.ResourceList{
-				corev1.ResourceCPU:    *resource.NewMilliQuantity(750, resource.DecimalSI),
-				corev1.ResourceMemory: *resource.NewQuantity(512*1024*1024, resource.BinarySI),
-			},
-			Requests: corev1.ResourceList{
-				corev1.ResourceCPU:    *resource.NewMilliQuantity(150, resource.DecimalSI),
-				corev1.ResourceMemory: *resource.NewQuantity(64*1024*1024, resource.BinarySI),
-			},
-		},
+		Resources: operandResources(instance, func(r *cv1.OperandResources) *corev1.ResourceRequirements { return r.PolicyEngine }),
 		LivenessProbe: &corev1.Probe{
 			FailureThreshold:    3,
 			SuccessThreshold:    1,
@@ -1031,3 +1264,24 @@ func checksumMap(m map[string]string) (string, error) {
 
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
+
+// operandResources returns the compute resources of an operand container,
+// which container selects from the spec of instance, or the defaults when
+// unset.
+func operandResources(instance *cv1.UpdateService, container func(*cv1.OperandResources) *corev1.ResourceRequirements) corev1.ResourceRequirements {
+	if instance.Spec.Resources != nil {
+		if resources := container(instance.Spec.Resources); resources != nil {
+			return *resources.DeepCopy()
+		}
+	}
+	return corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewMilliQuantity(750, resource.DecimalSI),
+			corev1.ResourceMemory: *resource.NewQuantity(512*1024*1024, resource.BinarySI),
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewMilliQuantity(150, resource.DecimalSI),
+			corev1.ResourceMemory: *resource.NewQuantity(64*1024*1024, resource.BinarySI),
+		},
+	}
+}
@@ -31,9 +31,10 @@ func Test_newKubeResources(t *testing.T) {
 	actual, actualErr := newKubeResources(
 		sample,
 		"image",
+		"operator-ns",
+		nil,
 		&corev1.Secret{Data: map[string][]byte{"a": []byte("b")}},
 		&corev1.ConfigMap{Data: map[string]string{"a": "b"}},
-		nil,
 	)
 	assert.Nil(t, actualErr)
 	dir := filepath.Join("testdata", "resources")
@@ -43,8 +44,11 @@ func Test_newKubeResources(t *testing.T) {
 		"deployment":               actual.deployment,
 		"graph_builder_service":    actual.graphBuilderService,
 		"network_policy":           actual.networkPolicy,
+		"service_monitor":          actual.serviceMonitor,
+		"prometheus_rule":          actual.prometheusRule,
+		"prometheus_role":          actual.prometheusRole,
+		"prometheus_role_binding":  actual.prometheusRoleBinding,
 		"pod_disruption_budget":    actual.podDisruptionBudget,
-		"policy_engine_old_route":  actual.policyEngineRoute,
 		"policy_engine_route":      actual.policyEngineRoute,
 		"policy_engine_service":    actual.policyEngineService,
 		"pull_secret":              actual.pullSecret,
@@ -213,11 +217,8 @@ func Test_egressPorts(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			t.Setenv("HTTP_PROXY", tc.httpProxy)
-			t.Setenv("HTTPS_PROXY", tc.httpsProxy)
-			t.Setenv("NO_PROXY", tc.noProxy)
-
-			got := egressPorts(tc.releases)
+			proxy := proxySettings{httpProxy: tc.httpProxy, httpsProxy: tc.httpsProxy, noProxy: tc.noProxy}
+			got := egressPorts(tc.releases, proxy)
 			assert.ElementsMatch(t, tc.expected, got)
 		})
 	}
@@ -305,9 +306,6 @@ func Test_newNetworkPolicy_egress_ports(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			t.Setenv("HTTP_PROXY", tc.httpProxy)
-			t.Setenv("HTTPS_PROXY", tc.httpsProxy)
-
 			instance := &cv1.UpdateService{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "test",
@@ -317,8 +315,8 @@ func Test_newNetworkPolicy_egress_ports(t *testing.T) {
 					Releases: tc.releases,
 				},
 			}
-			k := &kubeResources{}
-			np := k.newNetworkPolicy(instance)
+			k := &kubeResources{proxy: proxySettings{httpProxy: tc.httpProxy, httpsProxy: tc.httpsProxy}}
+			np := k.newNetworkPolicy(instance, "operator-ns")
 
 			// First egress rule should have specific ports
 			assert.NotEmpty(t, np.Spec.Egress)
@@ -356,9 +354,6 @@ func Test_newNetworkPolicy_egress_namespace(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			t.Setenv("HTTP_PROXY", "")
-			t.Setenv("HTTPS_PROXY", "")
-
 			instance := &cv1.UpdateService{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "test",
@@ -369,7 +364,7 @@ func Test_newNetworkPolicy_egress_namespace(t *testing.T) {
 				},
 			}
 			k := &kubeResources{}
-			np := k.newNetworkPolicy(instance)
+			np := k.newNetworkPolicy(instance, "operator-ns")
 
 			registryEgress := np.Spec.Egress[0]
 			if tc.wantNamespace == "" {
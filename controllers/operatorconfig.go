@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// OperatorConfigName is the name of the UpdateServiceOperatorConfig
+	// singleton. Its CRD rejects other names.
+	OperatorConfigName = "cluster"
+
+	// defaultMaxConcurrentReconciles is how many UpdateServices are
+	// reconciled concurrently, unless configured.
+	defaultMaxConcurrentReconciles = 1
+
+	// maxConcurrentReconcilesLimit is the most UpdateServices the
+	// controller reconciles concurrently, the maximum of
+	// maxConcurrentReconciles.
+	maxConcurrentReconcilesLimit = 10
+)
+
+// findOperatorConfig returns the spec of the UpdateServiceOperatorConfig, which
+// is empty when there is none.
+func findOperatorConfig(ctx context.Context, c client.Reader) (*cv1.UpdateServiceOperatorConfigSpec, error) {
+	config := &cv1.UpdateServiceOperatorConfig{}
+	err := c.Get(ctx, types.NamespacedName{Name: OperatorConfigName}, config)
+	if apiErrors.IsNotFound(err) {
+		return &cv1.UpdateServiceOperatorConfigSpec{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &config.Spec, nil
+}
+
+// operatorConfig returns the spec of the UpdateServiceOperatorConfig.
+func (r *UpdateServiceReconciler) operatorConfig(ctx context.Context) (*cv1.UpdateServiceOperatorConfigSpec, error) {
+	return findOperatorConfig(ctx, r.Client)
+}
+
+// resyncPeriod returns how often each UpdateService is reconciled when nothing
+// changes.
+func (r *UpdateServiceReconciler) resyncPeriod(ctx context.Context) time.Duration {
+	config, err := r.operatorConfig(ctx)
+	if err != nil || config.ResyncPeriod == nil {
+		return defaultResyncPeriod
+	}
+	return config.ResyncPeriod.Duration
+}
+
+// maxConcurrentReconciles returns how many UpdateServices are reconciled
+// concurrently.
+func maxConcurrentReconciles(config *cv1.UpdateServiceOperatorConfigSpec) int {
+	switch n := int(config.MaxConcurrentReconciles); {
+	case n <= 0:
+		return defaultMaxConcurrentReconciles
+	case n > maxConcurrentReconcilesLimit:
+		return maxConcurrentReconcilesLimit
+	default:
+		return n
+	}
+}
+
+// withOperatorDefaults returns a copy of instance whose unset operand image,
+// resources and exposure are set to the defaults of config, and the operand
+// image to operandImage when config has none either.
+func withOperatorDefaults(instance *cv1.UpdateService, config *cv1.UpdateServiceOperatorConfigSpec, operandImage string) *cv1.UpdateService {
+	instance = instance.DeepCopy()
+	spec := &instance.Spec
+	if spec.OperandImage == "" {
+		spec.OperandImage = config.OperandImage
+	}
+	if spec.OperandImage == "" {
+		spec.OperandImage = operandImage
+	}
+	if spec.Exposure == "" {
+		spec.Exposure = config.Exposure
+	}
+	if defaults := config.Resources; defaults != nil {
+		if spec.Resources == nil {
+			spec.Resources = &cv1.OperandResources{}
+		}
+		if spec.Resources.GraphBuilder == nil {
+			spec.Resources.GraphBuilder = defaults.GraphBuilder.DeepCopy()
+		}
+		if spec.Resources.PolicyEngine == nil {
+			spec.Resources.PolicyEngine = defaults.PolicyEngine.DeepCopy()
+		}
+	}
+	return instance
+}
+
+// reconcileLimiter bounds the number of concurrent reconciles by a limit
+// which can change at runtime, below the MaxConcurrentReconciles of the
+// controller.
+type reconcileLimiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	running int
+}
+
+// acquire sets the limit, waits until fewer reconciles than it run, and
+// returns the function ending the reconcile.
+func (l *reconcileLimiter) acquire(limit int) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mu)
+	}
+	if l.limit != limit {
+		l.limit = limit
+		l.cond.Broadcast()
+	}
+	for l.running >= l.limit {
+		l.cond.Wait()
+	}
+	l.running++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.running--
+		l.cond.Broadcast()
+	}
+}
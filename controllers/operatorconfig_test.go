@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func newResources(cpu string) *corev1.ResourceRequirements {
+	return &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+	}
+}
+
+func TestWithOperatorDefaults(t *testing.T) {
+	config := &cv1.UpdateServiceOperatorConfigSpec{
+		OperandImage: "config-image",
+		Exposure:     cv1.ExposureNone,
+		Resources: &cv1.OperandResources{
+			GraphBuilder: newResources("1"),
+			PolicyEngine: newResources("2"),
+		},
+	}
+
+	for name, test := range map[string]struct {
+		spec     cv1.UpdateServiceSpec
+		config   *cv1.UpdateServiceOperatorConfigSpec
+		expected cv1.UpdateServiceSpec
+	}{
+		"no config": {
+			config:   &cv1.UpdateServiceOperatorConfigSpec{},
+			expected: cv1.UpdateServiceSpec{OperandImage: testOperandImage},
+		},
+		"config": {
+			config: config,
+			expected: cv1.UpdateServiceSpec{
+				OperandImage: "config-image",
+				Exposure:     cv1.ExposureNone,
+				Resources:    config.Resources,
+			},
+		},
+		"overrides": {
+			spec: cv1.UpdateServiceSpec{
+				OperandImage: "instance-image",
+				Exposure:     cv1.ExposureRoute,
+				Resources:    &cv1.OperandResources{PolicyEngine: newResources("3")},
+			},
+			config: config,
+			expected: cv1.UpdateServiceSpec{
+				OperandImage: "instance-image",
+				Exposure:     cv1.ExposureRoute,
+				Resources: &cv1.OperandResources{
+					GraphBuilder: newResources("1"),
+					PolicyEngine: newResources("3"),
+				},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			instance := &cv1.UpdateService{Spec: test.spec}
+			actual := withOperatorDefaults(instance, test.config, testOperandImage)
+			assert.Equal(t, test.expected, actual.Spec)
+			assert.Equal(t, test.spec, instance.Spec, "the instance should not be changed")
+		})
+	}
+}
+
+func TestReconcileLimiter(t *testing.T) {
+	l := &reconcileLimiter{}
+	release := l.acquire(1)
+
+	acquired := make(chan func())
+	go func() { acquired <- l.acquire(1) }()
+	select {
+	case <-acquired:
+		t.Fatal("a second reconcile should wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// raising the limit takes effect immediately
+	l.acquire(2)()
+
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("the waiting reconcile should run once the first one ended")
+	}
+}
+
+func TestReconcileOperatorConfig(t *testing.T) {
+	config := &cv1.UpdateServiceOperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorConfigName},
+		Spec: cv1.UpdateServiceOperatorConfigSpec{
+			OperandImage: "config-image",
+			Exposure:     cv1.ExposureNone,
+			Resources:    &cv1.OperandResources{GraphBuilder: newResources("1")},
+			ResyncPeriod: &metav1.Duration{Duration: time.Minute},
+		},
+	}
+	updateservice := newDefaultUpdateService()
+	updateservice.Spec.Resources = &cv1.OperandResources{PolicyEngine: newResources("2")}
+	r := newTestReconciler(updateservice, newSecret(), config)
+	r.OperandImage = testOperandImage
+
+	result, err := r.Reconcile(context.TODO(), newRequest(updateservice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Minute, result.RequeueAfter)
+
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	containers := map[string]corev1.Container{}
+	for _, c := range deployment.Spec.Template.Spec.Containers {
+		containers[c.Name] = c
+	}
+	graphBuilder := containers[resources.graphBuilderContainer.Name]
+	policyEngine := containers[resources.policyEngineContainer.Name]
+	assert.Equal(t, "config-image", graphBuilder.Image)
+	assert.Equal(t, "config-image", policyEngine.Image)
+	assert.Equal(t, *newResources("1"), graphBuilder.Resources)
+	assert.Equal(t, *newResources("2"), policyEngine.Resources, "the UpdateService overrides the default resources")
+
+	// the policy engine is exposed inside of the cluster only
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: namePolicyEngineRoute(updateservice), Namespace: testNamespace}, &routev1.Route{})
+	assert.True(t, apiErrors.IsNotFound(err), "expected no Route, got %v", err)
+	instance := &cv1.UpdateService{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: updateservice.Name, Namespace: testNamespace}, instance); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "http://"+namePolicyEngineService(updateservice)+"."+testNamespace+".svc", instance.Status.PolicyEngineURI)
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	routev1 "github.com/openshift/api/route/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// specChanged passes updates which change an object's metadata or spec. It
+// drops the updates which only change its status, such as the operator's own
+// UpdateService status updates, and the ones which change nothing at all,
+// such as the informers' periodic resyncs.
+var specChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !equalIgnoringStatus(e.ObjectOld, e.ObjectNew)
+	},
+}
+
+// statusChanged passes updates which change the part of an object's status
+// returned by observed, which is the part the reconcile reads.
+func statusChanged[T client.Object](observed func(T) any) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldObj, ok := e.ObjectOld.(T)
+			if !ok {
+				return true
+			}
+			newObj, ok := e.ObjectNew.(T)
+			if !ok {
+				return true
+			}
+			return !equality.Semantic.DeepEqual(observed(oldObj), observed(newObj))
+		},
+	}
+}
+
+// deploymentRollout is the part of a Deployment's status read to follow its
+// rollouts, see operandRolledOut and observeGraphDataRollout.
+func deploymentRollout(deployment *appsv1.Deployment) any {
+	return struct {
+		ObservedGeneration int64
+		Complete, Failed   bool
+	}{
+		ObservedGeneration: deployment.Status.ObservedGeneration,
+		Complete:           deploymentRolloutComplete(deployment),
+		Failed:             deploymentRolloutFailed(deployment),
+	}
+}
+
+// graphDataPodResolution is the part of the graph-data pod's status read by
+// ensureGraphDataSHA: whether it completed, and the image digest it pulled.
+func graphDataPodResolution(pod *corev1.Pod) any {
+	imageIDs := make([]string, 0, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.ImageID != "" {
+			imageIDs = append(imageIDs, status.ImageID)
+		}
+	}
+	return struct {
+		Succeeded bool
+		ImageIDs  []string
+	}{
+		Succeeded: pod.Status.Phase == corev1.PodSucceeded,
+		ImageIDs:  imageIDs,
+	}
+}
+
+// routeIngress is the part of a Route's status from which the policy engine
+// URI is read.
+func routeIngress(route *routev1.Route) any {
+	return route.Status.Ingress
+}
+
+// equalIgnoringStatus reports whether a and b only differ in their status,
+// resourceVersion and managedFields.
+func equalIgnoringStatus(a, b client.Object) bool {
+	ua, err := runtime.DefaultUnstructuredConverter.ToUnstructured(a)
+	if err != nil {
+		return false
+	}
+	ub, err := runtime.DefaultUnstructuredConverter.ToUnstructured(b)
+	if err != nil {
+		return false
+	}
+	for _, u := range []map[string]interface{}{ua, ub} {
+		unstructured.RemoveNestedField(u, "status")
+		unstructured.RemoveNestedField(u, "metadata", "resourceVersion")
+		unstructured.RemoveNestedField(u, "metadata", "managedFields")
+	}
+	return equality.Semantic.DeepEqual(ua, ub)
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"testing"
+
+	routev1 "github.com/openshift/api/route/v1"
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// countReconciles returns how many of the updates from each object to the
+// next pass p, and so trigger a reconcile.
+func countReconciles(p predicate.Predicate, objs ...client.Object) int {
+	reconciles := 0
+	for i := 1; i < len(objs); i++ {
+		if p.Update(event.UpdateEvent{ObjectOld: objs[i-1], ObjectNew: objs[i]}) {
+			reconciles++
+		}
+	}
+	return reconciles
+}
+
+func TestSpecChanged(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	updateservice.ResourceVersion = "1"
+
+	resync := updateservice.DeepCopy()
+	statusUpdate := updateservice.DeepCopy()
+	statusUpdate.ResourceVersion = "2"
+	conditionsv1.SetStatusCondition(&statusUpdate.Status.Conditions, conditionsv1.Condition{
+		Type:   cv1.ConditionReconcileCompleted,
+		Status: corev1.ConditionTrue,
+		Reason: "Success",
+	})
+	specUpdate := statusUpdate.DeepCopy()
+	specUpdate.ResourceVersion = "3"
+	specUpdate.Generation = 2
+	specUpdate.Spec.Replicas = 3
+	labelUpdate := specUpdate.DeepCopy()
+	labelUpdate.ResourceVersion = "4"
+	labelUpdate.Labels = map[string]string{"team": "updates"}
+
+	tests := []struct {
+		name     string
+		old, new client.Object
+		expected bool
+	}{
+		{name: "resync", old: updateservice, new: resync, expected: false},
+		{name: "status", old: resync, new: statusUpdate, expected: false},
+		{name: "spec", old: statusUpdate, new: specUpdate, expected: true},
+		{name: "labels", old: specUpdate, new: labelUpdate, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, specChanged.Update(event.UpdateEvent{ObjectOld: test.old, ObjectNew: test.new}))
+		})
+	}
+	assert.True(t, specChanged.Create(event.CreateEvent{Object: updateservice}))
+	assert.True(t, specChanged.Delete(event.DeleteEvent{Object: updateservice}))
+}
+
+func TestGraphDataPodReconciles(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "graph-data-tag-digest", Namespace: testNamespace, ResourceVersion: "1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	updates := []client.Object{pod}
+	update := func(f func(*corev1.Pod)) {
+		next := updates[len(updates)-1].(*corev1.Pod).DeepCopy()
+		next.ResourceVersion += "1"
+		f(next)
+		updates = append(updates, next)
+	}
+	update(func(p *corev1.Pod) {
+		p.Status.Conditions = append(p.Status.Conditions, corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue})
+	})
+	update(func(p *corev1.Pod) {
+		p.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: NameInitContainerGraphData}}
+	})
+	update(func(p *corev1.Pod) {
+		p.Status.Phase = corev1.PodRunning
+		p.Status.ContainerStatuses[0].ImageID = "quay.io/example/graph-data@sha256:0123"
+	})
+	update(func(p *corev1.Pod) {
+		p.Status.Conditions = append(p.Status.Conditions, corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionTrue})
+	})
+	update(func(p *corev1.Pod) {}) // resync
+	update(func(p *corev1.Pod) {
+		p.Status.Conditions[1].Status = corev1.ConditionFalse
+	})
+	update(func(p *corev1.Pod) {
+		p.Status.Phase = corev1.PodSucceeded
+	})
+
+	assert.Equal(t, len(updates)-1, countReconciles(predicate.Funcs{}, updates...))
+	// only resolving the digest and completing matter
+	assert.Equal(t, 2, countReconciles(statusChanged(graphDataPodResolution), updates...))
+}
+
+func TestDeploymentReconciles(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: testName, Namespace: testNamespace, Generation: 1, ResourceVersion: "1"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			ReadyReplicas:      2,
+			AvailableReplicas:  2,
+		},
+	}
+	updates := []client.Object{deployment}
+	update := func(f func(*appsv1.Deployment)) {
+		next := updates[len(updates)-1].(*appsv1.Deployment).DeepCopy()
+		next.ResourceVersion += "1"
+		f(next)
+		updates = append(updates, next)
+	}
+	// a rollout of a new pod template
+	update(func(d *appsv1.Deployment) {
+		d.Generation = 2
+		d.Spec.Template.Annotations = map[string]string{GraphDataImageAnnotation: "sha256:0123"}
+	})
+	update(func(d *appsv1.Deployment) {
+		d.Status.ObservedGeneration = 2
+		d.Status.UpdatedReplicas = 0
+	})
+	update(func(d *appsv1.Deployment) {
+		d.Status.Replicas = 3
+		d.Status.UpdatedReplicas = 1
+	})
+	update(func(d *appsv1.Deployment) { d.Status.ReadyReplicas = 3 })
+	update(func(d *appsv1.Deployment) { d.Status.AvailableReplicas = 3 })
+	update(func(d *appsv1.Deployment) {
+		d.Status.Replicas = 2
+		d.Status.UpdatedReplicas = 2
+		d.Status.ReadyReplicas = 2
+		d.Status.AvailableReplicas = 2
+	})
+	update(func(d *appsv1.Deployment) {}) // resync
+
+	assert.Equal(t, len(updates)-1, countReconciles(predicate.Funcs{}, updates...))
+	// the new template, its observation, and the end of its rollout
+	assert.Equal(t, 3, countReconciles(predicate.Or(specChanged, statusChanged(deploymentRollout)), updates...))
+}
+
+func TestRouteReconciles(t *testing.T) {
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: testName + "-route", Namespace: testNamespace, ResourceVersion: "1"},
+		Spec:       routev1.RouteSpec{Host: "updates.example.com"},
+	}
+	resync := route.DeepCopy()
+	admitted := route.DeepCopy()
+	admitted.ResourceVersion = "2"
+	admitted.Status.Ingress = []routev1.RouteIngress{{
+		Host:       "updates.example.com",
+		RouterName: "default",
+		Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue}},
+	}}
+
+	p := predicate.Or(specChanged, statusChanged(routeIngress))
+	assert.Equal(t, 1, countReconciles(p, route, resync, admitted))
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+
+	apicfgv1 "github.com/openshift/api/config/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// ProxyConfigName is the name of the cluster-wide Proxy.
+const ProxyConfigName = "cluster"
+
+// proxySettings are the proxy settings of the graph builder of an
+// UpdateService.
+type proxySettings struct {
+	httpProxy  string
+	httpsProxy string
+	noProxy    string
+	// trustedCA is whether the cluster-wide proxy trusts additional CAs,
+	// which are injected into the cluster-wide trusted CA bundle.
+	trustedCA bool
+}
+
+// enabled reports whether the graph builder uses a proxy.
+func (p proxySettings) enabled() bool {
+	return p.httpProxy != "" || p.httpsProxy != "" || p.noProxy != ""
+}
+
+// newProxySettings returns the proxy settings of instance. proxy is the
+// cluster-wide Proxy, which is nil when there is none. The cluster-wide
+// settings are read from its status, which also lists the cluster networks
+// in noProxy.
+func newProxySettings(instance *cv1.UpdateService, proxy *apicfgv1.Proxy) proxySettings {
+	settings := proxySettings{}
+	if proxy != nil {
+		settings.trustedCA = proxy.Spec.TrustedCA.Name != ""
+	}
+	config := instance.Spec.Proxy
+	if config == nil {
+		config = &cv1.ProxyConfig{Mode: cv1.ProxyCluster}
+	}
+	switch config.Mode {
+	case cv1.ProxyCustom:
+		settings.httpProxy = config.HTTPProxy
+		settings.httpsProxy = config.HTTPSProxy
+		settings.noProxy = config.NoProxy
+	case cv1.ProxyNone:
+	default:
+		if proxy != nil {
+			settings.httpProxy = proxy.Status.HTTPProxy
+			settings.httpsProxy = proxy.Status.HTTPSProxy
+			settings.noProxy = proxy.Status.NoProxy
+		}
+	}
+	return settings
+}
+
+// findProxy returns the cluster-wide Proxy, or nil when there is none, such as
+// on clusters without the config.openshift.io API.
+func findProxy(ctx context.Context, c client.Reader) (*apicfgv1.Proxy, error) {
+	proxy := &apicfgv1.Proxy{}
+	err := c.Get(ctx, types.NamespacedName{Name: ProxyConfigName}, proxy)
+	if apiErrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
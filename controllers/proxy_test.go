@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	apicfgv1 "github.com/openshift/api/config/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func TestNewProxySettings(t *testing.T) {
+	clusterProxy := newProxy(apicfgv1.ProxyStatus{
+		HTTPProxy:  "http://proxy.example.com:3128",
+		HTTPSProxy: "http://proxy.example.com:3129",
+		NoProxy:    ".cluster.local,.svc,10.0.0.0/16",
+	})
+	trustedCAProxy := clusterProxy.DeepCopy()
+	trustedCAProxy.Spec.TrustedCA.Name = "user-ca-bundle"
+	custom := &cv1.ProxyConfig{
+		Mode:       cv1.ProxyCustom,
+		HTTPSProxy: "http://custom.example.com:8080",
+		NoProxy:    "registry.example.com",
+	}
+
+	for name, test := range map[string]struct {
+		proxy    *apicfgv1.Proxy
+		config   *cv1.ProxyConfig
+		expected proxySettings
+	}{
+		"no cluster proxy": {},
+		"cluster proxy": {
+			proxy: clusterProxy,
+			expected: proxySettings{
+				httpProxy:  "http://proxy.example.com:3128",
+				httpsProxy: "http://proxy.example.com:3129",
+				noProxy:    ".cluster.local,.svc,10.0.0.0/16",
+			},
+		},
+		"cluster mode": {
+			proxy:  clusterProxy,
+			config: &cv1.ProxyConfig{Mode: cv1.ProxyCluster},
+			expected: proxySettings{
+				httpProxy:  "http://proxy.example.com:3128",
+				httpsProxy: "http://proxy.example.com:3129",
+				noProxy:    ".cluster.local,.svc,10.0.0.0/16",
+			},
+		},
+		"cluster trusted CA": {
+			proxy: trustedCAProxy,
+			expected: proxySettings{
+				httpProxy:  "http://proxy.example.com:3128",
+				httpsProxy: "http://proxy.example.com:3129",
+				noProxy:    ".cluster.local,.svc,10.0.0.0/16",
+				trustedCA:  true,
+			},
+		},
+		"custom": {
+			proxy:  clusterProxy,
+			config: custom,
+			expected: proxySettings{
+				httpsProxy: "http://custom.example.com:8080",
+				noProxy:    "registry.example.com",
+			},
+		},
+		"custom without cluster proxy": {
+			config: custom,
+			expected: proxySettings{
+				httpsProxy: "http://custom.example.com:8080",
+				noProxy:    "registry.example.com",
+			},
+		},
+		"none": {
+			proxy:    clusterProxy,
+			config:   &cv1.ProxyConfig{Mode: cv1.ProxyNone},
+			expected: proxySettings{},
+		},
+		"none keeps the trusted CA": {
+			proxy:    trustedCAProxy,
+			config:   &cv1.ProxyConfig{Mode: cv1.ProxyNone},
+			expected: proxySettings{trustedCA: true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			instance := newDefaultUpdateService()
+			instance.Spec.Proxy = test.config
+			assert.Equal(t, test.expected, newProxySettings(instance, test.proxy))
+		})
+	}
+}
+
+func Test_noProxy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		registry string
+		noProxy  string
+		expected bool
+	}{
+		{
+			name:     "empty list",
+			registry: "quay.io",
+		},
+		{
+			name:     "wildcard",
+			registry: "quay.io",
+			noProxy:  "*",
+			expected: true,
+		},
+		{
+			name:     "hostname with port",
+			registry: "registry.example.com:5000",
+			noProxy:  "quay.io, registry.example.com",
+			expected: true,
+		},
+		{
+			name:     "subdomain",
+			registry: "registry.example.com",
+			noProxy:  ".example.com",
+			expected: true,
+		},
+		{
+			name:     "CIDR",
+			registry: "10.0.1.2:5000",
+			noProxy:  "10.0.0.0/16",
+			expected: true,
+		},
+		{
+			name:     "not listed",
+			registry: "quay.io",
+			noProxy:  ".cluster.local,.svc,10.0.0.0/16",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, noProxy(tc.registry, tc.noProxy))
+		})
+	}
+}
+
+func TestReconcileProxy(t *testing.T) {
+	proxy := newProxy(apicfgv1.ProxyStatus{
+		HTTPProxy: "http://proxy.example.com:3128",
+		NoProxy:   ".cluster.local,.svc",
+	})
+
+	for name, test := range map[string]struct {
+		proxy       *apicfgv1.Proxy
+		config      *cv1.ProxyConfig
+		expectedEnv map[string]string
+		expectedCA  bool
+	}{
+		"no cluster proxy": {
+			expectedEnv: map[string]string{},
+		},
+		"cluster proxy": {
+			proxy: proxy,
+			expectedEnv: map[string]string{
+				"HTTP_PROXY": "http://proxy.example.com:3128",
+				"NO_PROXY":   ".cluster.local,.svc",
+			},
+			expectedCA: true,
+		},
+		"custom": {
+			proxy: proxy,
+			config: &cv1.ProxyConfig{
+				Mode:       cv1.ProxyCustom,
+				HTTPSProxy: "http://custom.example.com:8080",
+			},
+			expectedEnv: map[string]string{
+				"HTTPS_PROXY": "http://custom.example.com:8080",
+			},
+			expectedCA: true,
+		},
+		"opted out": {
+			proxy:       proxy,
+			config:      &cv1.ProxyConfig{Mode: cv1.ProxyNone},
+			expectedEnv: map[string]string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			updateservice := newDefaultUpdateService()
+			updateservice.Spec.Proxy = test.config
+			objs := []runtime.Object{updateservice, newSecret()}
+			if test.proxy != nil {
+				objs = append(objs, test.proxy)
+			}
+			r := newTestReconciler(objs...)
+
+			if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+				t.Fatal(err)
+			}
+
+			deployment := &appsv1.Deployment{}
+			if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, deployment); err != nil {
+				t.Fatal(err)
+			}
+			env := map[string]string{}
+			for _, c := range deployment.Spec.Template.Spec.Containers {
+				if c.Name != NameContainerGraphBuilder {
+					continue
+				}
+				for _, e := range c.Env {
+					switch e.Name {
+					case "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY":
+						env[e.Name] = e.Value
+					}
+				}
+			}
+			assert.Equal(t, test.expectedEnv, env)
+
+			err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameClusterTrustedCAConfig(updateservice), Namespace: testNamespace}, &corev1.ConfigMap{})
+			if test.expectedCA {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, apiErrors.IsNotFound(err), "expected no cluster CA ConfigMap, got %v", err)
+			}
+		})
+	}
+}
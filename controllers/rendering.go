@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// renderingHash hashes the rendered resources of an UpdateService, which
+// include their inputs such as the pull secret and the CAs, together with the
+// graph-data image digest the Deployment is annotated with.
+func renderingHash(resources *kubeResources, imageSHA string, rolloutHeld bool) (string, error) {
+	data, err := json.Marshal([]interface{}{resources.objects(), imageSHA, rolloutHeld})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// appliedRendering is a rendering applied by this operator process, and the
+// number of changes to the UpdateService resources seen before it.
+type appliedRendering struct {
+	hash    string
+	changes int64
+}
+
+// appliedRenderings remembers, per UpdateService, the rendering last applied
+// by this operator process and counts the changes to its resources, so that
+// a reconcile which would apply the same rendering to unchanged resources can
+// skip it. A new process applies every rendering once, as its resources may
+// have drifted while no operator was watching them.
+type appliedRenderings struct {
+	mu      sync.Mutex
+	applied map[types.NamespacedName]appliedRendering
+	changes map[types.NamespacedName]int64
+}
+
+// changesOf returns the number of changes seen to the resources of the
+// UpdateService, to be passed to record once they are applied.
+func (a *appliedRenderings) changesOf(key types.NamespacedName) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.changes[key]
+}
+
+// record remembers that the rendering with the given hash was applied, after
+// the given number of changes to the resources.
+func (a *appliedRenderings) record(key types.NamespacedName, hash string, changes int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.applied == nil {
+		a.applied = map[types.NamespacedName]appliedRendering{}
+	}
+	a.applied[key] = appliedRendering{hash: hash, changes: changes}
+}
+
+// upToDate reports whether the rendering with the given hash was applied,
+// and the resources have not changed since.
+func (a *appliedRenderings) upToDate(key types.NamespacedName, hash string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	applied, ok := a.applied[key]
+	return ok && applied.hash == hash && applied.changes == a.changes[key]
+}
+
+// resourceChanged counts a change to a resource of the UpdateService.
+func (a *appliedRenderings) resourceChanged(key types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.changes == nil {
+		a.changes = map[types.NamespacedName]int64{}
+	}
+	a.changes[key]++
+}
+
+// forget drops what is known about a deleted UpdateService.
+func (a *appliedRenderings) forget(key types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.applied, key)
+	delete(a.changes, key)
+}
+
+// watchChanges returns a predicate which counts the events for resources
+// controlled by an UpdateService. It filters no events, and follows the
+// predicates which drop the irrelevant ones.
+func (a *appliedRenderings) watchChanges() predicate.Predicate {
+	changed := func(obj client.Object) bool {
+		if ref := metav1.GetControllerOf(obj); ref != nil && ref.Kind == "UpdateService" {
+			a.resourceChanged(types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.Name})
+		}
+		return true
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return changed(e.Object) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return changed(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return changed(e.ObjectNew) },
+		GenericFunc: func(e event.GenericEvent) bool { return changed(e.Object) },
+	}
+}
+
+// renderingApplied reports whether the resources of the UpdateService need
+// not be applied again: the rendering with the given hash was applied for its
+// current generation, the resources have not changed since, and no rollout is
+// waiting for its schedule.
+func (r *UpdateServiceReconciler) renderingApplied(instance *cv1.UpdateService, hash string) bool {
+	return hash != "" &&
+		instance.Status.ObservedGeneration == instance.Generation &&
+		instance.Status.LastAppliedHash == hash &&
+		!conditionsv1.IsStatusConditionTrue(instance.Status.Conditions, cv1.ConditionRolloutPending) &&
+		r.renderings.upToDate(types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}, hash)
+}
+
+// keepAppliedStatus copies to status what the skipped ensure functions
+// reported when the rendering was applied.
+func keepAppliedStatus(status *cv1.UpdateServiceStatus, applied cv1.UpdateServiceStatus) {
+	status.PolicyEngineURI = applied.PolicyEngineURI
+	for _, c := range applied.Conditions {
+		if conditionsv1.FindStatusCondition(status.Conditions, c.Type) == nil {
+			status.Conditions = append(status.Conditions, c)
+		}
+	}
+}
+
+// updateStatus writes the status of instanceCopy, unless it only differs from
+// the status of instance in the heartbeat times of its conditions. Conditions
+// which kept their status keep their lastTransitionTime.
+func (r *UpdateServiceReconciler) updateStatus(ctx context.Context, reqLogger logr.Logger, instance, instanceCopy *cv1.UpdateService) {
+	for i := range instanceCopy.Status.Conditions {
+		c := &instanceCopy.Status.Conditions[i]
+		if previous := conditionsv1.FindStatusCondition(instance.Status.Conditions, c.Type); previous != nil && previous.Status == c.Status {
+			c.LastTransitionTime = previous.LastTransitionTime
+		}
+	}
+	if equality.Semantic.DeepEqual(withoutHeartbeats(instance.Status), withoutHeartbeats(instanceCopy.Status)) {
+		reqLogger.V(1).Info("Status unchanged")
+		return
+	}
+	if err := r.Client.Status().Update(ctx, instanceCopy); err != nil {
+		reqLogger.Error(err, "Failed to update Status")
+	}
+}
+
+// withoutHeartbeats returns a copy of status without the heartbeat times of
+// its conditions.
+func withoutHeartbeats(status cv1.UpdateServiceStatus) cv1.UpdateServiceStatus {
+	status = *status.DeepCopy()
+	for i := range status.Conditions {
+		status.Conditions[i].LastHeartbeatTime = metav1.Time{}
+	}
+	return status
+}
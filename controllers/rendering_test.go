@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// writeCounter counts the writes of a fake client, other than to the
+// graph-data pod.
+type writeCounter struct {
+	resources, status int
+}
+
+func (w *writeCounter) funcs() interceptor.Funcs {
+	count := func(obj client.Object) {
+		if _, ok := obj.(*corev1.Pod); !ok {
+			w.resources++
+		}
+	}
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			count(obj)
+			return c.Create(ctx, obj, opts...)
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			count(obj)
+			return c.Update(ctx, obj, opts...)
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			dryRun := &client.PatchOptions{}
+			dryRun.ApplyOptions(opts)
+			if len(dryRun.DryRun) == 0 {
+				count(obj)
+			}
+			return fakeApply(ctx, c, obj, patch, opts...)
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			w.status++
+			return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+		},
+	}
+}
+
+func (w *writeCounter) reset() {
+	*w = writeCounter{}
+}
+
+func TestReconcileSkipsAppliedRendering(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	request := newRequest(updateservice)
+	writes := &writeCounter{}
+	c := newFakeClientBuilder().WithRuntimeObjects(updateservice, newSecret()).WithStatusSubresource(&cv1.UpdateService{}).
+		WithInterceptorFuncs(writes.funcs()).Build()
+	r := newTestReconciler()
+	r.Client = c
+
+	reconcile := func() *cv1.UpdateService {
+		t.Helper()
+		writes.reset()
+		if _, err := r.Reconcile(context.TODO(), request); err != nil {
+			t.Fatal(err)
+		}
+		instance := &cv1.UpdateService{}
+		if err := r.Client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+			t.Fatal(err)
+		}
+		return instance
+	}
+
+	instance := reconcile()
+	assert.NotZero(t, writes.resources)
+	assert.Equal(t, 1, writes.status)
+	assert.NotEmpty(t, instance.Status.LastAppliedHash)
+	hash := instance.Status.LastAppliedHash
+
+	t.Run("unchanged", func(t *testing.T) {
+		instance := reconcile()
+		assert.Zero(t, writes.resources, "the resources should not be applied again")
+		assert.Zero(t, writes.status, "the status should not be written again")
+		assert.Equal(t, hash, instance.Status.LastAppliedHash)
+		assert.True(t, conditionsv1.IsStatusConditionTrue(instance.Status.Conditions, cv1.ConditionReconcileCompleted))
+	})
+
+	t.Run("resource changed", func(t *testing.T) {
+		deployment := &appsv1.Deployment{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, deployment); err != nil {
+			t.Fatal(err)
+		}
+		r.renderings.watchChanges().Update(event.UpdateEvent{ObjectOld: deployment, ObjectNew: deployment})
+		reconcile()
+		assert.NotZero(t, writes.resources, "a changed resource should be applied again")
+		reconcile()
+		assert.Zero(t, writes.resources)
+	})
+
+	t.Run("generation changed", func(t *testing.T) {
+		instance.Generation++
+		instance.Spec.Replicas = 2
+		if err := r.Client.Update(context.TODO(), instance); err != nil {
+			t.Fatal(err)
+		}
+		instance = reconcile()
+		assert.NotZero(t, writes.resources)
+		assert.Equal(t, instance.Generation, instance.Status.ObservedGeneration)
+		assert.NotEqual(t, hash, instance.Status.LastAppliedHash, "the replicas are part of the rendering")
+	})
+
+	t.Run("new process", func(t *testing.T) {
+		reconcile()
+		assert.Zero(t, writes.resources)
+		r = newTestReconciler()
+		r.Client = c
+		reconcile()
+		assert.NotZero(t, writes.resources, "a new process should apply the resources once")
+	})
+}
+
+func TestUpdateStatusKeepsTransitionTimes(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	transition := metav1.NewTime(time.Now().Add(-5 * time.Minute).Truncate(time.Second))
+	updateservice.Status.Conditions = []conditionsv1.Condition{{
+		Type:               cv1.ConditionReconcileCompleted,
+		Status:             corev1.ConditionTrue,
+		Reason:             "Success",
+		LastTransitionTime: transition,
+		LastHeartbeatTime:  transition,
+	}}
+	writes := &writeCounter{}
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(updateservice).WithStatusSubresource(&cv1.UpdateService{}).
+		WithInterceptorFuncs(writes.funcs()).Build()
+
+	unchanged := updateservice.DeepCopy()
+	unchanged.Status.Conditions[0].LastTransitionTime = metav1.Now()
+	unchanged.Status.Conditions[0].LastHeartbeatTime = metav1.Now()
+	r.updateStatus(context.TODO(), log, updateservice, unchanged)
+	assert.Zero(t, writes.status)
+
+	changed := unchanged.DeepCopy()
+	changed.Status.PolicyEngineURI = "https://updates.example.com"
+	r.updateStatus(context.TODO(), log, updateservice, changed)
+	assert.Equal(t, 1, writes.status)
+	assert.True(t, transition.Equal(&changed.Status.Conditions[0].LastTransitionTime))
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// defaultGraphDataHistoryLimit is the number of graph-data revisions kept in
+// status when spec.graphDataHistoryLimit is unset.
+const defaultGraphDataHistoryLimit = 10
+
+// ensureGraphDataRollout records the resolved graph-data digest in the status
+// history, tracks whether its rollout became Available, and pins the
+// graph-data init container to an earlier digest when the UpdateService is
+// pinned or the latest rollout failed. It returns the value to use for the
+// graph-data image annotation on the Deployment.
+func (r *UpdateServiceReconciler) ensureGraphDataRollout(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService,
+	resources *kubeResources, imageSHA string) (string, error) {
+
+	ref, err := parseImageReference(instance.Spec.GraphDataImage)
+	if err != nil {
+		return imageSHA, nil
+	}
+	digest := digestFromImageID(imageSHA)
+	if digest == "" {
+		digest = ref.Digest
+	}
+	if digest == "" {
+		// nothing resolved yet, so there is nothing to record
+		return imageSHA, nil
+	}
+	latest := ref.Name() + "@" + digest
+
+	found := &appsv1.Deployment{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: resources.deployment.Name, Namespace: resources.deployment.Namespace}, found)
+	if err != nil && apiErrors.IsNotFound(err) {
+		found = nil
+	} else if err != nil {
+		r.handleErr(reqLogger, instance, "GetDeploymentFailed", err)
+		return "", err
+	}
+
+	limit := int(instance.Spec.GraphDataHistoryLimit)
+	if limit <= 0 {
+		limit = defaultGraphDataHistoryLimit
+	}
+	served := ""
+	if found != nil {
+		served = servedGraphDataDigest(found)
+	}
+	// the latest, pinned and served revisions are kept, so that the pin
+	// still matches and the rollouts of the latest and served digests are
+	// still tracked
+	history := recordGraphDataRevision(instance.Status.GraphDataHistory, latest, metav1.NewTime(r.now()), limit,
+		digest, digestFromImageID(instance.Annotations[GraphDataPinAnnotation]), served)
+	if served != "" {
+		observeGraphDataRollout(history, served, found)
+	}
+	instance.Status.GraphDataHistory = history
+
+	target, reason, message := latest, "Latest", fmt.Sprintf("Serving the latest graph-data image %s", latest)
+	if pin, ok := instance.Annotations[GraphDataPinAnnotation]; ok {
+		if pinned := findGraphDataRevision(history, digestFromImageID(pin)); pinned != nil {
+			target, reason = pinned.Image, "Pinned"
+			message = fmt.Sprintf("Pinned to %s by the %s annotation", pinned.Image, GraphDataPinAnnotation)
+		} else {
+			reason = "InvalidPin"
+			message = fmt.Sprintf("The %s annotation %q does not match a digest in status.graphDataHistory; serving the latest graph-data image %s",
+				GraphDataPinAnnotation, pin, latest)
+		}
+	} else if revision := findGraphDataRevision(history, digest); revision != nil && revision.RolledBack {
+		if previous := graphDataRollbackTarget(history, digest); previous != nil {
+			target, reason = previous.Image, "RolloutFailed"
+			message = fmt.Sprintf("The rollout of %s never became Available; rolled back to %s", latest, previous.Image)
+		} else {
+			reason = "NoRollbackTarget"
+			message = fmt.Sprintf("The rollout of %s never became Available, and there is no earlier Available graph-data image to roll back to", latest)
+		}
+	}
+
+	status := corev1.ConditionFalse
+	if target != latest {
+		status = corev1.ConditionTrue
+		reqLogger.Info("Rolling back graph-data image", "Image", target, "Reason", reason)
+	}
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+		Type:    cv1.ConditionGraphDataRolledBack,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+
+	if target == latest {
+		return imageSHA, nil
+	}
+	resources.pinGraphDataImage(target)
+	return target, nil
+}
+
+// recordGraphDataRevision returns history with image prepended, unless its
+// digest is already listed, trimmed to the limit most recent revisions. The
+// revisions of the keep digests are never trimmed; the oldest other revisions
+// are trimmed in their place.
+func recordGraphDataRevision(history []cv1.GraphDataRevision, image string, now metav1.Time, limit int, keep ...string) []cv1.GraphDataRevision {
+	updated := make([]cv1.GraphDataRevision, 0, len(history)+1)
+	if findGraphDataRevision(history, digestFromImageID(image)) == nil {
+		updated = append(updated, cv1.GraphDataRevision{Image: image, FirstSeen: now})
+	}
+	updated = append(updated, history...)
+	if len(updated) <= limit {
+		return updated
+	}
+
+	kept := func(revision cv1.GraphDataRevision) bool {
+		digest := digestFromImageID(revision.Image)
+		return digest != "" && slices.Contains(keep, digest)
+	}
+	others := limit
+	for _, revision := range updated {
+		if kept(revision) {
+			others--
+		}
+	}
+	trimmed := make([]cv1.GraphDataRevision, 0, limit)
+	for _, revision := range updated {
+		if kept(revision) {
+			trimmed = append(trimmed, revision)
+		} else if others > 0 {
+			trimmed = append(trimmed, revision)
+			others--
+		}
+	}
+	return trimmed
+}
+
+// findGraphDataRevision returns the history entry for digest, or nil.
+func findGraphDataRevision(history []cv1.GraphDataRevision, digest string) *cv1.GraphDataRevision {
+	if digest == "" {
+		return nil
+	}
+	for i := range history {
+		if digestFromImageID(history[i].Image) == digest {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+// graphDataRollbackTarget returns the most recent Available revision older
+// than digest, or nil.
+func graphDataRollbackTarget(history []cv1.GraphDataRevision, digest string) *cv1.GraphDataRevision {
+	older := false
+	for i := range history {
+		if digestFromImageID(history[i].Image) == digest {
+			older = true
+			continue
+		}
+		if older && history[i].Available && !history[i].RolledBack {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+// observeGraphDataRollout marks the revision for the served digest Available
+// once the Deployment rollout completes, or RolledBack if the rollout exceeded
+// its progress deadline before it ever became Available.
+func observeGraphDataRollout(history []cv1.GraphDataRevision, served string, deployment *appsv1.Deployment) {
+	revision := findGraphDataRevision(history, served)
+	if revision == nil || deployment.Status.ObservedGeneration < deployment.Generation {
+		return
+	}
+	if deploymentRolloutComplete(deployment) {
+		revision.Available = true
+		revision.RolledBack = false
+	} else if !revision.Available && deploymentRolloutFailed(deployment) {
+		revision.RolledBack = true
+	}
+}
+
+// deploymentRolloutComplete mirrors the checks of 'oc rollout status'.
+func deploymentRolloutComplete(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	s := deployment.Status
+	return s.UpdatedReplicas == replicas && s.Replicas == s.UpdatedReplicas && s.AvailableReplicas == s.UpdatedReplicas
+}
+
+// deploymentRolloutFailed reports whether the Deployment controller gave up
+// waiting for the new pods to become ready.
+func deploymentRolloutFailed(deployment *appsv1.Deployment) bool {
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing {
+			return c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded"
+		}
+	}
+	return false
+}
+
+// servedGraphDataDigest returns the graph-data digest the Deployment's pod
+// template serves, from a by-digest init container image or from the
+// graph-data image annotation.
+func servedGraphDataDigest(deployment *appsv1.Deployment) string {
+	for _, c := range deployment.Spec.Template.Spec.InitContainers {
+		if c.Name == NameInitContainerGraphData && strings.Contains(c.Image, "@sha256:") {
+			return digestFromImageID(c.Image)
+		}
+	}
+	return digestFromImageID(deployment.Spec.Template.Annotations[GraphDataImageAnnotation])
+}
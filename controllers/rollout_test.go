@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const testGraphDataRepository = "quay.io/cincinnati/graph-data"
+
+func Test_recordGraphDataRevision(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(earlier.Add(time.Hour))
+	first := testGraphDataRepository + "@" + testDigest
+	second := testGraphDataRepository + "@" + testOtherDigest
+	third := testGraphDataRepository + "@sha256:3333333333333333333333333333333333333333333333333333333333333333"
+
+	for _, tc := range []struct {
+		name     string
+		history  []cv1.GraphDataRevision
+		image    string
+		limit    int
+		keep     []string
+		expected []cv1.GraphDataRevision
+	}{
+		{
+			name:     "empty history",
+			image:    first,
+			limit:    10,
+			expected: []cv1.GraphDataRevision{{Image: first, FirstSeen: now}},
+		},
+		{
+			name:     "known digest keeps its first-seen time",
+			history:  []cv1.GraphDataRevision{{Image: first, FirstSeen: earlier, Available: true}},
+			image:    first,
+			limit:    10,
+			expected: []cv1.GraphDataRevision{{Image: first, FirstSeen: earlier, Available: true}},
+		},
+		{
+			name:    "new digest is prepended",
+			history: []cv1.GraphDataRevision{{Image: first, FirstSeen: earlier, Available: true}},
+			image:   second,
+			limit:   10,
+			expected: []cv1.GraphDataRevision{
+				{Image: second, FirstSeen: now},
+				{Image: first, FirstSeen: earlier, Available: true},
+			},
+		},
+		{
+			name:     "history is trimmed to the limit",
+			history:  []cv1.GraphDataRevision{{Image: first, FirstSeen: earlier, Available: true}},
+			image:    second,
+			limit:    1,
+			expected: []cv1.GraphDataRevision{{Image: second, FirstSeen: now}},
+		},
+		{
+			name: "kept revision is not trimmed",
+			history: []cv1.GraphDataRevision{
+				{Image: second, FirstSeen: earlier},
+				{Image: first, FirstSeen: earlier, Available: true},
+			},
+			image: third,
+			limit: 2,
+			keep:  []string{testDigest, ""},
+			expected: []cv1.GraphDataRevision{
+				{Image: third, FirstSeen: now},
+				{Image: first, FirstSeen: earlier, Available: true},
+			},
+		},
+		{
+			name: "kept revisions may exceed the limit",
+			history: []cv1.GraphDataRevision{
+				{Image: second, FirstSeen: earlier},
+				{Image: first, FirstSeen: earlier, Available: true},
+			},
+			image: third,
+			limit: 1,
+			keep:  []string{testDigest, testOtherDigest},
+			expected: []cv1.GraphDataRevision{
+				{Image: second, FirstSeen: earlier},
+				{Image: first, FirstSeen: earlier, Available: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, recordGraphDataRevision(tc.history, tc.image, now, tc.limit, tc.keep...))
+		})
+	}
+}
+
+func Test_observeGraphDataRollout(t *testing.T) {
+	replicas := int32(2)
+	for _, tc := range []struct {
+		name               string
+		revision           cv1.GraphDataRevision
+		status             appsv1.DeploymentStatus
+		expectedAvailable  bool
+		expectedRolledBack bool
+	}{
+		{
+			name:     "rollout in progress",
+			revision: cv1.GraphDataRevision{Image: testGraphDataRepository + "@" + testDigest},
+			status:   appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2},
+		},
+		{
+			name:              "rollout complete",
+			revision:          cv1.GraphDataRevision{Image: testGraphDataRepository + "@" + testDigest},
+			status:            appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			expectedAvailable: true,
+		},
+		{
+			name:     "rollout exceeded its progress deadline",
+			revision: cv1.GraphDataRevision{Image: testGraphDataRepository + "@" + testDigest},
+			status: appsv1.DeploymentStatus{
+				Replicas:          3,
+				UpdatedReplicas:   1,
+				AvailableReplicas: 2,
+				Conditions: []appsv1.DeploymentCondition{{
+					Type:   appsv1.DeploymentProgressing,
+					Status: corev1.ConditionFalse,
+					Reason: "ProgressDeadlineExceeded",
+				}},
+			},
+			expectedRolledBack: true,
+		},
+		{
+			name:     "previously available digest is not rolled back",
+			revision: cv1.GraphDataRevision{Image: testGraphDataRepository + "@" + testDigest, Available: true},
+			status: appsv1.DeploymentStatus{
+				Conditions: []appsv1.DeploymentCondition{{
+					Type:   appsv1.DeploymentProgressing,
+					Status: corev1.ConditionFalse,
+					Reason: "ProgressDeadlineExceeded",
+				}},
+			},
+			expectedAvailable: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			history := []cv1.GraphDataRevision{tc.revision}
+			deployment := &appsv1.Deployment{
+				Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: tc.status,
+			}
+			observeGraphDataRollout(history, testDigest, deployment)
+			assert.Equal(t, tc.expectedAvailable, history[0].Available)
+			assert.Equal(t, tc.expectedRolledBack, history[0].RolledBack)
+		})
+	}
+}
+
+func TestEnsureGraphDataRollout(t *testing.T) {
+	latest := testGraphDataRepository + "@" + testOtherDigest
+	previous := testGraphDataRepository + "@" + testDigest
+	history := func() []cv1.GraphDataRevision {
+		return []cv1.GraphDataRevision{
+			{Image: latest, FirstSeen: metav1.Now()},
+			{Image: previous, FirstSeen: metav1.Now(), Available: true},
+		}
+	}
+	failedDeployment := func(us *cv1.UpdateService) *appsv1.Deployment {
+		resources, err := newKubeResources(us, testOperandImage, testNamespace, nil, newSecret(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deployment := resources.deployment
+		deployment.Spec.Template.Annotations[GraphDataImageAnnotation] = latest
+		deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type:   appsv1.DeploymentProgressing,
+			Status: corev1.ConditionFalse,
+			Reason: "ProgressDeadlineExceeded",
+		}}
+		return deployment
+	}
+
+	for _, tc := range []struct {
+		name              string
+		pin               string
+		existingObjs      func(us *cv1.UpdateService) []runtime.Object
+		expectedImage     string
+		expectedStatus    corev1.ConditionStatus
+		expectedReason    string
+		expectedHistory   int
+		expectedPinnedPod bool
+	}{
+		{
+			name: "latest digest is served",
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us}
+			},
+			expectedImage:   latest,
+			expectedStatus:  corev1.ConditionFalse,
+			expectedReason:  "Latest",
+			expectedHistory: 2,
+		},
+		{
+			name: "failed rollout is rolled back",
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, failedDeployment(us)}
+			},
+			expectedImage:     previous,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "RolloutFailed",
+			expectedHistory:   2,
+			expectedPinnedPod: true,
+		},
+		{
+			name: "pinned by digest",
+			pin:  testDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us}
+			},
+			expectedImage:     previous,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "Pinned",
+			expectedHistory:   2,
+			expectedPinnedPod: true,
+		},
+		{
+			name: "pin to an unknown digest is ignored",
+			pin:  "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us}
+			},
+			expectedImage:   latest,
+			expectedStatus:  corev1.ConditionFalse,
+			expectedReason:  "InvalidPin",
+			expectedHistory: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			us := newDefaultUpdateService()
+			us.Spec.GraphDataImage = testGraphDataRepository + ":latest"
+			us.Status.GraphDataHistory = history()
+			if tc.pin != "" {
+				us.Annotations = map[string]string{GraphDataPinAnnotation: tc.pin}
+			}
+			r := newTestReconciler(tc.existingObjs(us)...)
+			resources, err := newKubeResources(us, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			image, err := r.ensureGraphDataRollout(context.TODO(), log, us, resources, latest)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expectedImage, image)
+			assert.Len(t, us.Status.GraphDataHistory, tc.expectedHistory)
+			if tc.expectedPinnedPod {
+				assert.Equal(t, tc.expectedImage, resources.graphDataInitContainer.Image)
+			} else {
+				assert.Equal(t, us.Spec.GraphDataImage, resources.graphDataInitContainer.Image)
+			}
+			condition := conditionsv1.FindStatusCondition(us.Status.Conditions, cv1.ConditionGraphDataRolledBack)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedStatus, condition.Status)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+			}
+		})
+	}
+}
+
+func TestEnsureGraphDataRolloutKeepsPinnedRevision(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	pinned := testGraphDataRepository + "@" + testDigest
+	latest := testGraphDataRepository + "@" + testOtherDigest
+	us := newDefaultUpdateService()
+	us.Spec.GraphDataImage = testGraphDataRepository + ":latest"
+	us.Spec.GraphDataHistoryLimit = 1
+	us.Annotations = map[string]string{GraphDataPinAnnotation: testDigest}
+	us.Status.GraphDataHistory = []cv1.GraphDataRevision{{Image: pinned, FirstSeen: metav1.NewTime(now.Add(-time.Hour)), Available: true}}
+	r := newTestReconciler(us)
+	r.clock = func() time.Time { return now }
+	resources, err := newKubeResources(us, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image, err := r.ensureGraphDataRollout(context.TODO(), log, us, resources, latest)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, pinned, image)
+	assert.Equal(t, []cv1.GraphDataRevision{
+		{Image: latest, FirstSeen: metav1.NewTime(now)},
+		{Image: pinned, FirstSeen: metav1.NewTime(now.Add(-time.Hour)), Available: true},
+	}, us.Status.GraphDataHistory)
+	condition := conditionsv1.FindStatusCondition(us.Status.Conditions, cv1.ConditionGraphDataRolledBack)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, "Pinned", condition.Reason)
+	}
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/robfig/cron/v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+// evaluateRolloutSchedule reports whether one of the schedule's maintenance
+// windows is open at now, and otherwise when the next window opens.
+func evaluateRolloutSchedule(schedule *cv1.RolloutSchedule, now time.Time) (bool, time.Time, error) {
+	location := time.UTC
+	if schedule.TimeZone != "" {
+		var err error
+		location, err = time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid rollout schedule time zone %q: %w", schedule.TimeZone, err)
+		}
+	}
+	now = now.In(location)
+
+	var next time.Time
+	for _, window := range schedule.Windows {
+		s, err := cron.ParseStandard(window.Schedule)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid maintenance window schedule %q: %w", window.Schedule, err)
+		}
+		// A window is open if it opened at most duration ago.
+		if opened := s.Next(now.Add(-window.Duration.Duration)); !opened.After(now) {
+			return true, time.Time{}, nil
+		}
+		if opens := s.Next(now); next.IsZero() || opens.Before(next) {
+			next = opens
+		}
+	}
+	return false, next, nil
+}
+
+// pendingRolloutChanges describes the differences between the pod templates
+// of the found and the updated Deployment.
+func pendingRolloutChanges(found, updated *appsv1.Deployment) []string {
+	var changes []string
+	foundAnnotations := found.Spec.Template.Annotations
+	updatedAnnotations := updated.Spec.Template.Annotations
+	if foundAnnotations[GraphDataImageAnnotation] != updatedAnnotations[GraphDataImageAnnotation] {
+		changes = append(changes, fmt.Sprintf("graph-data image %s", updatedAnnotations[GraphDataImageAnnotation]))
+	} else if graphDataInitContainerImage(found) != graphDataInitContainerImage(updated) {
+		changes = append(changes, fmt.Sprintf("graph-data image %s", graphDataInitContainerImage(updated)))
+	}
+	if foundAnnotations[GraphBuilderConfigHashAnnotation] != updatedAnnotations[GraphBuilderConfigHashAnnotation] {
+		changes = append(changes, "graph-builder configuration")
+	}
+	if foundAnnotations[EnvConfigHashAnnotation] != updatedAnnotations[EnvConfigHashAnnotation] {
+		changes = append(changes, "environment configuration")
+	}
+	if len(changes) == 0 && !reflect.DeepEqual(found.Spec.Template, updated.Spec.Template) {
+		changes = append(changes, "pod template")
+	}
+	return changes
+}
+
+func graphDataInitContainerImage(deployment *appsv1.Deployment) string {
+	for _, c := range deployment.Spec.Template.Spec.InitContainers {
+		if c.Name == NameInitContainerGraphData {
+			return c.Image
+		}
+	}
+	return ""
+}
+
+// holdRollout applies the UpdateService's rollout schedule to an update of
+// the Deployment, and reports whether the update of the pod template is held.
+// Outside a maintenance window, the pod template of the found Deployment is
+// kept, and the held changes are reported by the RolloutPending condition.
+// Failed rollouts are never held, so they can be rolled back.
+func (r *UpdateServiceReconciler) holdRollout(reqLogger logr.Logger, instance *cv1.UpdateService, found, updated *appsv1.Deployment) bool {
+	changes := pendingRolloutChanges(found, updated)
+	if len(changes) == 0 {
+		conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+			Type:    cv1.ConditionRolloutPending,
+			Status:  corev1.ConditionFalse,
+			Reason:  "UpToDate",
+			Message: "No changes are waiting for a maintenance window",
+		})
+		return false
+	}
+
+	open, next, err := evaluateRolloutSchedule(instance.Spec.RolloutSchedule, r.now())
+	reason, message := "OutsideMaintenanceWindow", ""
+	switch {
+	case err != nil:
+		reason, message = "InvalidRolloutSchedule", err.Error()
+	case open:
+		message = "Rolling out during the current maintenance window"
+	case deploymentRolloutFailed(found):
+		open, message = true, "Rolling out outside of the maintenance windows to replace a failed rollout"
+	default:
+		message = fmt.Sprintf("Waiting for the maintenance window at %s", next.Format(time.RFC3339))
+	}
+	if open {
+		conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+			Type:    cv1.ConditionRolloutPending,
+			Status:  corev1.ConditionFalse,
+			Reason:  "InMaintenanceWindow",
+			Message: fmt.Sprintf("%s. Pending changes: %s", message, strings.Join(changes, ", ")),
+		})
+		return false
+	}
+
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+		Type:    cv1.ConditionRolloutPending,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: fmt.Sprintf("%s. Pending changes: %s", message, strings.Join(changes, ", ")),
+	})
+	reqLogger.Info("Holding Deployment rollout", "Reason", reason, "Changes", changes)
+	return true
+}
+
+// holdConfig reports whether the update of config, a ConfigMap whose hash is
+// annotated on the pod template under annotation, is held along with the
+// rollout. Pods which restart outside of a maintenance window read the
+// ConfigMap, so it keeps the configuration of the rolled-out pod template.
+func (r *UpdateServiceReconciler) holdConfig(ctx context.Context, instance *cv1.UpdateService, resources *kubeResources, config *corev1.ConfigMap, annotation string) (bool, error) {
+	if instance.Spec.RolloutSchedule == nil {
+		return false, nil
+	}
+	found, err := r.findApplied(ctx, resources.deployment)
+	if err != nil || found == nil {
+		return false, err
+	}
+	foundDeployment := found.(*appsv1.Deployment)
+	if foundDeployment.Spec.Template.Annotations[annotation] == resources.deployment.Spec.Template.Annotations[annotation] ||
+		deploymentRolloutFailed(foundDeployment) {
+		return false, nil
+	}
+	// a missing ConfigMap is created, as the pods could not start without it
+	if foundConfig, err := r.findApplied(ctx, config); err != nil || foundConfig == nil {
+		return false, err
+	}
+	open, _, err := evaluateRolloutSchedule(instance.Spec.RolloutSchedule, r.now())
+	return err != nil || !open, nil
+}
+
+// rolloutRequeueAfter returns how long to wait before the next reconcile,
+// which is sooner than usual when a held rollout's maintenance window opens.
+func (r *UpdateServiceReconciler) rolloutRequeueAfter(instance *cv1.UpdateService, requeueAfter time.Duration) time.Duration {
+	if instance.Spec.RolloutSchedule == nil || !conditionsv1.IsStatusConditionTrue(instance.Status.Conditions, cv1.ConditionRolloutPending) {
+		return requeueAfter
+	}
+	now := r.now()
+	_, next, err := evaluateRolloutSchedule(instance.Spec.RolloutSchedule, now)
+	if err != nil || next.IsZero() {
+		return requeueAfter
+	}
+	// land just inside the window
+	if untilNext := next.Sub(now) + time.Second; untilNext < requeueAfter {
+		return untilNext
+	}
+	return requeueAfter
+}
+
+// now returns the reconciler's current time.
+func (r *UpdateServiceReconciler) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func Test_evaluateRolloutSchedule(t *testing.T) {
+	weeknights := cv1.MaintenanceWindow{Schedule: "0 22 * * 1-5", Duration: metav1.Duration{Duration: 2 * time.Hour}}
+	for _, tc := range []struct {
+		name         string
+		schedule     cv1.RolloutSchedule
+		now          time.Time
+		expectedOpen bool
+		expectedNext time.Time
+		expectedErr  bool
+	}{
+		{
+			name:         "inside a window",
+			schedule:     cv1.RolloutSchedule{Windows: []cv1.MaintenanceWindow{weeknights}},
+			now:          time.Date(2024, 5, 6, 23, 30, 0, 0, time.UTC),
+			expectedOpen: true,
+		},
+		{
+			name:         "window closes after its duration",
+			schedule:     cv1.RolloutSchedule{Windows: []cv1.MaintenanceWindow{weeknights}},
+			now:          time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2024, 5, 7, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "weekend waits for monday",
+			schedule:     cv1.RolloutSchedule{Windows: []cv1.MaintenanceWindow{weeknights}},
+			now:          time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2024, 5, 6, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "earliest of several windows",
+			schedule: cv1.RolloutSchedule{Windows: []cv1.MaintenanceWindow{
+				weeknights,
+				{Schedule: "@daily", Duration: metav1.Duration{Duration: time.Hour}},
+			}},
+			now:          time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "time zone",
+			schedule:     cv1.RolloutSchedule{TimeZone: "Europe/Berlin", Windows: []cv1.MaintenanceWindow{weeknights}},
+			now:          time.Date(2024, 5, 6, 20, 30, 0, 0, time.UTC),
+			expectedOpen: true,
+		},
+		{
+			name:        "invalid time zone",
+			schedule:    cv1.RolloutSchedule{TimeZone: "Mars/Olympus_Mons", Windows: []cv1.MaintenanceWindow{weeknights}},
+			now:         time.Date(2024, 5, 6, 20, 30, 0, 0, time.UTC),
+			expectedErr: true,
+		},
+		{
+			name:        "invalid schedule",
+			schedule:    cv1.RolloutSchedule{Windows: []cv1.MaintenanceWindow{{Schedule: "every evening"}}},
+			now:         time.Date(2024, 5, 6, 20, 30, 0, 0, time.UTC),
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			open, next, err := evaluateRolloutSchedule(&tc.schedule, tc.now)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOpen, open)
+			assert.True(t, tc.expectedNext.Equal(next), "expected next window at %s, got %s", tc.expectedNext, next)
+		})
+	}
+}
+
+func TestEnsureDeploymentRolloutSchedule(t *testing.T) {
+	closed := time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC)
+	open := time.Date(2024, 5, 6, 23, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name              string
+		now               time.Time
+		failedRollout     bool
+		expectedImage     string
+		expectedStatus    corev1.ConditionStatus
+		expectedReason    string
+		expectedRequeueIn time.Duration
+	}{
+		{
+			name:              "change is held outside of the window",
+			now:               closed,
+			expectedImage:     testGraphDataImage,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "OutsideMaintenanceWindow",
+			expectedRequeueIn: 5 * time.Minute,
+		},
+		{
+			name:              "change is rolled out inside the window",
+			now:               open,
+			expectedImage:     "quay.io/cincinnati/graph-data:next",
+			expectedStatus:    corev1.ConditionFalse,
+			expectedReason:    "InMaintenanceWindow",
+			expectedRequeueIn: 5 * time.Minute,
+		},
+		{
+			name:              "failed rollout is replaced outside of the window",
+			now:               closed,
+			failedRollout:     true,
+			expectedImage:     "quay.io/cincinnati/graph-data:next",
+			expectedStatus:    corev1.ConditionFalse,
+			expectedReason:    "InMaintenanceWindow",
+			expectedRequeueIn: 5 * time.Minute,
+		},
+		{
+			name:              "requeue when the window opens",
+			now:               time.Date(2024, 5, 6, 21, 58, 0, 0, time.UTC),
+			expectedImage:     testGraphDataImage,
+			expectedStatus:    corev1.ConditionTrue,
+			expectedReason:    "OutsideMaintenanceWindow",
+			expectedRequeueIn: 2*time.Minute + time.Second,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updateservice := newDefaultUpdateService()
+			updateservice.Spec.RolloutSchedule = &cv1.RolloutSchedule{
+				Windows: []cv1.MaintenanceWindow{{Schedule: "0 22 * * 1-5", Duration: metav1.Duration{Duration: 2 * time.Hour}}},
+			}
+			existing, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.failedRollout {
+				existing.deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+					Type:   appsv1.DeploymentProgressing,
+					Status: corev1.ConditionFalse,
+					Reason: "ProgressDeadlineExceeded",
+				}}
+			}
+			r := newTestReconciler(updateservice, existing.deployment)
+			r.clock = func() time.Time { return tc.now }
+
+			updateservice.Spec.GraphDataImage = "quay.io/cincinnati/graph-data:next"
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.ensureDeployment(context.TODO(), log, updateservice, resources, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			found := &appsv1.Deployment{}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: updateservice.Namespace}, found)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.expectedImage, found.Spec.Template.Spec.InitContainers[0].Image)
+			condition := conditionsv1.FindStatusCondition(updateservice.Status.Conditions, cv1.ConditionRolloutPending)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedStatus, condition.Status)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+				assert.Contains(t, condition.Message, "graph-data image")
+			}
+			assert.Equal(t, tc.expectedRequeueIn, r.rolloutRequeueAfter(updateservice, 5*time.Minute))
+		})
+	}
+}
+
+func TestReconcileHoldsConfigOutsideOfWindow(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	updateservice.Spec.RolloutSchedule = &cv1.RolloutSchedule{
+		Windows: []cv1.MaintenanceWindow{{Schedule: "0 22 * * 1-5", Duration: metav1.Duration{Duration: 2 * time.Hour}}},
+	}
+	existing, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(updateservice, newSecret(), existing.graphBuilderConfig, existing.envConfig, existing.deployment)
+	r.clock = func() time.Time { return time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC) }
+
+	// the releases repository changes outside of the window
+	found := &cv1.UpdateService{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: updateservice.Name, Namespace: updateservice.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	found.Spec.Releases = "quay.io/cincinnati/other-releases"
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &corev1.ConfigMap{}
+	configName := types.NamespacedName{Name: nameConfig(updateservice), Namespace: updateservice.Namespace}
+	if err := r.Client.Get(context.TODO(), configName, config); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, existing.graphBuilderConfig.Data["gb.toml"], config.Data["gb.toml"])
+	deployment := &appsv1.Deployment{}
+	deploymentName := types.NamespacedName{Name: nameDeployment(updateservice), Namespace: updateservice.Namespace}
+	if err := r.Client.Get(context.TODO(), deploymentName, deployment); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, existing.graphBuilderConfigHash, deployment.Spec.Template.Annotations[GraphBuilderConfigHashAnnotation])
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: updateservice.Name, Namespace: updateservice.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	condition := conditionsv1.FindStatusCondition(found.Status.Conditions, cv1.ConditionRolloutPending)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Contains(t, condition.Message, "graph-builder configuration")
+	}
+
+	// both are updated once the window opens
+	r.clock = func() time.Time { return time.Date(2024, 5, 6, 23, 0, 0, 0, time.UTC) }
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), configName, config); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, config.Data["gb.toml"], "cincinnati/other-releases")
+	if err := r.Client.Get(context.TODO(), deploymentName, deployment); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, existing.graphBuilderConfigHash, deployment.Spec.Template.Annotations[GraphBuilderConfigHashAnnotation])
+}
+
+func TestEnsureEnvConfigRolloutSchedule(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	updateservice.Spec.RolloutSchedule = &cv1.RolloutSchedule{
+		Windows: []cv1.MaintenanceWindow{{Schedule: "0 22 * * 1-5", Duration: metav1.Duration{Duration: 2 * time.Hour}}},
+	}
+	existing, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := types.NamespacedName{Name: nameEnvConfig(updateservice), Namespace: updateservice.Namespace}
+	for _, tc := range []struct {
+		name      string
+		now       time.Time
+		missing   bool
+		expectedV string
+	}{
+		{name: "held outside of the window", now: time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC), expectedV: "vv"},
+		{name: "applied inside the window", now: time.Date(2024, 5, 6, 23, 0, 0, 0, time.UTC), expectedV: "vvv"},
+		{name: "created outside of the window", now: time.Date(2024, 5, 4, 12, 0, 0, 0, time.UTC), missing: true, expectedV: "vvv"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestReconciler(updateservice, existing.deployment)
+			if !tc.missing {
+				r = newTestReconciler(updateservice, existing.deployment, existing.envConfig)
+			}
+			r.clock = func() time.Time { return tc.now }
+
+			// an operator upgrade changes the environment
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resources.envConfig.Data["pe.log.verbosity"] = "vvv"
+			resources.deployment.Spec.Template.Annotations[EnvConfigHashAnnotation] = "changed"
+			if err := r.ensureEnvConfig(context.TODO(), log, updateservice, resources); err != nil {
+				t.Fatal(err)
+			}
+
+			found := &corev1.ConfigMap{}
+			if err := r.Client.Get(context.TODO(), name, found); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.expectedV, found.Data["pe.log.verbosity"])
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// operandCheckInterval is how often the operand is checked when the
+	// Deployment has not changed. It is slightly shorter than the periodic
+	// requeue so that every periodic reconcile runs a check.
+	operandCheckInterval = 4 * time.Minute
+
+	// operandRequestTimeout bounds each request to the operand.
+	operandRequestTimeout = 10 * time.Second
+
+	// graphMaxBytes bounds the size of a graph read from the policy engine.
+	graphMaxBytes = 64 << 20
+
+	cincinnatiGraphMediaType = "application/vnd.redhat.cincinnati.v1+json"
+)
+
+// cincinnatiGraph is the subset of the Cincinnati graph format that is
+// needed to summarize a graph.
+type cincinnatiGraph struct {
+	Nodes []struct {
+		Version string `json:"version"`
+	} `json:"nodes"`
+	Edges            [][2]int `json:"edges"`
+	ConditionalEdges []struct {
+		Edges []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"edges"`
+	} `json:"conditionalEdges"`
+}
+
+// operandHTTPClient returns the client used to query operand Services.
+func (r *UpdateServiceReconciler) operandHTTPClient() *http.Client {
+	if r.operandHTTP != nil {
+		return r.operandHTTP
+	}
+	// In-cluster Services are never reached through the cluster proxy.
+	return &http.Client{
+		Timeout:   operandRequestTimeout,
+		Transport: &http.Transport{},
+	}
+}
+
+// operandRolledOut reports whether the Deployment's pods all run its current
+// pod template, so that checking the operand checks that template.
+func operandRolledOut(deployment *appsv1.Deployment) bool {
+	return deployment.Status.ObservedGeneration >= deployment.Generation && deploymentRolloutComplete(deployment)
+}
+
+// operandCheckDue reports whether an operand check which last ran at
+// lastCheck, against the given Deployment generation, should run again.
+func (r *UpdateServiceReconciler) operandCheckDue(lastCheck metav1.Time, observedGeneration int64, deployment *appsv1.Deployment) bool {
+	return observedGeneration != deployment.Generation || r.now().Sub(lastCheck.Time) >= operandCheckInterval
+}
+
+// queryChannelGraph fetches the graph the policy engine serves for channel.
+func queryChannelGraph(ctx context.Context, client *http.Client, baseURL, channel string) (*cincinnatiGraph, error) {
+	u := baseURL + "/api/upgrades_info/graph?" + url.Values{"channel": []string{channel}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", cincinnatiGraphMediaType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, graphMaxBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+	}
+	graph := &cincinnatiGraph{}
+	if err := json.Unmarshal(body, graph); err != nil {
+		return nil, fmt.Errorf("GET %s: invalid graph: %w", u, err)
+	}
+	return graph, nil
+}
+
+// summarizeChannelGraph counts the nodes and edges of a channel's graph.
+func summarizeChannelGraph(channel string, graph *cincinnatiGraph) cv1.ChannelGraph {
+	edges := len(graph.Edges)
+	for _, conditional := range graph.ConditionalEdges {
+		edges += len(conditional.Edges)
+	}
+	return cv1.ChannelGraph{
+		Name:  channel,
+		Nodes: int32(len(graph.Nodes)),
+		Edges: int32(edges),
+	}
+}
+
+// ensureGraphServing queries the policy engine for each expected channel once
+// the Deployment rollout completed, and periodically afterwards, and sets the
+// GraphServing condition from the result.
+func (r *UpdateServiceReconciler) ensureGraphServing(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+	if len(instance.Spec.ExpectedChannels) == 0 {
+		instance.Status.GraphServing = nil
+		return nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: resources.deployment.Name, Namespace: resources.deployment.Namespace}, deployment)
+	if err != nil && !apiErrors.IsNotFound(err) {
+		r.handleErr(reqLogger, instance, "GetDeploymentFailed", err)
+		return err
+	}
+
+	previous := instance.Status.GraphServing
+	due := previous == nil ||
+		!sameChannels(previous.Channels, instance.Spec.ExpectedChannels) ||
+		r.operandCheckDue(previous.LastCheckTime, previous.ObservedDeploymentGeneration, deployment)
+	if err == nil && operandRolledOut(deployment) && due {
+		baseURL := serviceURL(resources.policyEngineService, "policy-engine")
+		client := r.operandHTTPClient()
+		status := &cv1.GraphServingStatus{
+			LastCheckTime:                metav1.NewTime(r.now()),
+			ObservedDeploymentGeneration: deployment.Generation,
+		}
+		for _, channel := range instance.Spec.ExpectedChannels {
+			graph, err := queryChannelGraph(ctx, client, baseURL, channel)
+			if err != nil {
+				reqLogger.Info("Failed to query the policy engine", "Channel", channel, "Error", err.Error())
+				status.Channels = append(status.Channels, cv1.ChannelGraph{Name: channel, Error: err.Error()})
+				continue
+			}
+			status.Channels = append(status.Channels, summarizeChannelGraph(channel, graph))
+		}
+		instance.Status.GraphServing = status
+	}
+
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, graphServingCondition(instance.Status.GraphServing))
+	return nil
+}
+
+// serviceURL returns the in-cluster HTTP URL of a Service's named port.
+func serviceURL(service *corev1.Service, portName string) string {
+	var port int32
+	for _, p := range service.Spec.Ports {
+		if p.Name == portName {
+			port = p.Port
+		}
+	}
+	return fmt.Sprintf("http://%s.%s.svc:%d", service.Name, service.Namespace, port)
+}
+
+// sameChannels reports whether a check covered exactly the given channels.
+func sameChannels(checked []cv1.ChannelGraph, expected []string) bool {
+	if len(checked) != len(expected) {
+		return false
+	}
+	for i := range checked {
+		if checked[i].Name != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// graphServingCondition derives the GraphServing condition from the most
+// recent check.
+func graphServingCondition(status *cv1.GraphServingStatus) conditionsv1.Condition {
+	if status == nil {
+		return conditionsv1.Condition{
+			Type:    cv1.ConditionGraphServing,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "NotChecked",
+			Message: "Waiting for the Deployment rollout to complete before querying the policy engine",
+		}
+	}
+
+	var failed, empty, served []string
+	for _, c := range status.Channels {
+		switch {
+		case c.Error != "":
+			failed = append(failed, fmt.Sprintf("%s (%s)", c.Name, c.Error))
+		case c.Nodes == 0:
+			empty = append(empty, c.Name)
+		default:
+			served = append(served, fmt.Sprintf("%s (%d nodes, %d edges)", c.Name, c.Nodes, c.Edges))
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		return conditionsv1.Condition{
+			Type:    cv1.ConditionGraphServing,
+			Status:  corev1.ConditionFalse,
+			Reason:  "GraphRequestFailed",
+			Message: fmt.Sprintf("Failed to query the policy engine for channels: %s", strings.Join(failed, "; ")),
+		}
+	case len(empty) > 0:
+		return conditionsv1.Condition{
+			Type:    cv1.ConditionGraphServing,
+			Status:  corev1.ConditionFalse,
+			Reason:  "ChannelsMissing",
+			Message: fmt.Sprintf("The policy engine serves no releases for channels: %s", strings.Join(empty, ", ")),
+		}
+	}
+	return conditionsv1.Condition{
+		Type:    cv1.ConditionGraphServing,
+		Status:  corev1.ConditionTrue,
+		Reason:  "GraphServed",
+		Message: fmt.Sprintf("The policy engine serves %s", strings.Join(served, ", ")),
+	}
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const testGraph = `{
+  "nodes": [
+    {"version": "4.15.1", "payload": "quay.io/openshift-release-dev/ocp-release@sha256:1111", "metadata": {}},
+    {"version": "4.15.2", "payload": "quay.io/openshift-release-dev/ocp-release@sha256:2222", "metadata": {}},
+    {"version": "4.15.3", "payload": "quay.io/openshift-release-dev/ocp-release@sha256:3333", "metadata": {}}
+  ],
+  "edges": [[0, 1], [1, 2]],
+  "conditionalEdges": [
+    {"edges": [{"from": "4.15.1", "to": "4.15.3"}], "risks": []}
+  ]
+}`
+
+// redirectTransport sends every request to a test server, keeping the path
+// and query.
+type redirectTransport struct {
+	target *url.URL
+	hosts  []string
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, req.URL.Host)
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newPolicyEngineServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/upgrades_info/graph" {
+			http.NotFound(w, req)
+			return
+		}
+		switch req.URL.Query().Get("channel") {
+		case "stable-4.15":
+			w.Header().Set("Content-Type", cincinnatiGraphMediaType)
+			_, _ = w.Write([]byte(testGraph))
+		case "broken":
+			http.Error(w, `{"kind":"upstream_error"}`, http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte(`{"nodes":[],"edges":[],"conditionalEdges":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_queryChannelGraph(t *testing.T) {
+	server := newPolicyEngineServer(t)
+
+	graph, err := queryChannelGraph(context.TODO(), server.Client(), server.URL, "stable-4.15")
+	if assert.NoError(t, err) {
+		assert.Equal(t, cv1.ChannelGraph{Name: "stable-4.15", Nodes: 3, Edges: 3}, summarizeChannelGraph("stable-4.15", graph))
+	}
+
+	_, err = queryChannelGraph(context.TODO(), server.Client(), server.URL, "broken")
+	assert.ErrorContains(t, err, "502 Bad Gateway")
+}
+
+func TestEnsureGraphServing(t *testing.T) {
+	server := newPolicyEngineServer(t)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name             string
+		channels         []string
+		rolledOut        bool
+		previous         *cv1.GraphServingStatus
+		expectedQueries  int
+		expectedStatus   corev1.ConditionStatus
+		expectedReason   string
+		expectedChannels []cv1.ChannelGraph
+	}{
+		{
+			name:           "no expected channels",
+			rolledOut:      true,
+			expectedStatus: "",
+		},
+		{
+			name:            "rollout in progress",
+			channels:        []string{"stable-4.15"},
+			expectedQueries: 0,
+			expectedStatus:  corev1.ConditionUnknown,
+			expectedReason:  "NotChecked",
+		},
+		{
+			name:             "graph served",
+			channels:         []string{"stable-4.15"},
+			rolledOut:        true,
+			expectedQueries:  1,
+			expectedStatus:   corev1.ConditionTrue,
+			expectedReason:   "GraphServed",
+			expectedChannels: []cv1.ChannelGraph{{Name: "stable-4.15", Nodes: 3, Edges: 3}},
+		},
+		{
+			name:             "empty channel",
+			channels:         []string{"stable-4.15", "fast-4.99"},
+			rolledOut:        true,
+			expectedQueries:  2,
+			expectedStatus:   corev1.ConditionFalse,
+			expectedReason:   "ChannelsMissing",
+			expectedChannels: []cv1.ChannelGraph{{Name: "stable-4.15", Nodes: 3, Edges: 3}, {Name: "fast-4.99"}},
+		},
+		{
+			name:            "request failed",
+			channels:        []string{"broken"},
+			rolledOut:       true,
+			expectedQueries: 1,
+			expectedStatus:  corev1.ConditionFalse,
+			expectedReason:  "GraphRequestFailed",
+		},
+		{
+			name:      "recent check is reused",
+			channels:  []string{"stable-4.15"},
+			rolledOut: true,
+			previous: &cv1.GraphServingStatus{
+				LastCheckTime: metav1.NewTime(now.Add(-time.Minute)),
+				Channels:      []cv1.ChannelGraph{{Name: "stable-4.15", Nodes: 1}},
+			},
+			expectedQueries:  0,
+			expectedStatus:   corev1.ConditionTrue,
+			expectedReason:   "GraphServed",
+			expectedChannels: []cv1.ChannelGraph{{Name: "stable-4.15", Nodes: 1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			updateservice := newDefaultUpdateService()
+			updateservice.Spec.ExpectedChannels = tc.channels
+			updateservice.Status.GraphServing = tc.previous
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := resources.deployment.DeepCopy()
+			if tc.rolledOut {
+				deployment.Status.Replicas = updateservice.Spec.Replicas
+				deployment.Status.UpdatedReplicas = updateservice.Spec.Replicas
+				deployment.Status.AvailableReplicas = updateservice.Spec.Replicas
+			}
+			r := newTestReconciler(updateservice, deployment)
+			transport := &redirectTransport{target: target}
+			r.operandHTTP = &http.Client{Transport: transport}
+			r.clock = func() time.Time { return now }
+
+			if err := r.ensureGraphServing(context.TODO(), log, updateservice, resources); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Len(t, transport.hosts, tc.expectedQueries)
+			for _, host := range transport.hosts {
+				assert.Equal(t, "foo-policy-engine.bar.svc:80", host)
+			}
+			condition := conditionsv1.FindStatusCondition(updateservice.Status.Conditions, cv1.ConditionGraphServing)
+			if tc.expectedStatus == "" {
+				assert.Nil(t, condition)
+				assert.Nil(t, updateservice.Status.GraphServing)
+				return
+			}
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedStatus, condition.Status)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+			}
+			if tc.expectedChannels != nil {
+				assert.Equal(t, tc.expectedChannels, updateservice.Status.GraphServing.Channels)
+			}
+		})
+	}
+}
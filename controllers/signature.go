@@ -0,0 +1,563 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	// cosignSignatureAnnotation is the manifest layer annotation in which
+	// cosign stores the base64-encoded signature of the layer's payload.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// cosignSignatureType is the critical.type of a cosign simple-signing payload.
+	cosignSignatureType = "cosign container image signature"
+
+	// maxRegistryResponseBytes bounds the size of manifests and payloads read
+	// from a registry during verification.
+	maxRegistryResponseBytes = 4 * 1024 * 1024
+)
+
+// errRolloutHeld is returned when no verified graph-data digest is available,
+// and the Deployment must not be created or updated.
+var errRolloutHeld = errors.New("no verified graph-data image digest is available")
+
+// imageReference is a parsed container image pull spec.
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImageReference splits a pull spec like registry/repo:tag or
+// registry/repo@sha256:... into its components. Pull specs without a registry
+// host are resolved against docker.io, like container runtimes do.
+func parseImageReference(pullSpec string) (imageReference, error) {
+	ref := imageReference{}
+	name := pullSpec
+	if i := strings.Index(name, "@"); i > -1 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !strings.HasPrefix(ref.Digest, "sha256:") || len(ref.Digest) != len("sha256:")+64 {
+			return ref, fmt.Errorf("unsupported digest %q in image %q", ref.Digest, pullSpec)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > -1 && !strings.Contains(name[i:], "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+	segments := strings.SplitN(name, "/", 2)
+	if len(segments) == 2 && (strings.ContainsAny(segments[0], ".:") || segments[0] == "localhost") {
+		ref.Registry = segments[0]
+		ref.Repository = segments[1]
+	} else {
+		ref.Registry = "docker.io"
+		ref.Repository = name
+		if len(segments) == 1 {
+			ref.Repository = "library/" + name
+		}
+	}
+	if ref.Repository == "" {
+		return ref, fmt.Errorf("failed to parse repository from image %q", pullSpec)
+	}
+	return ref, nil
+}
+
+// Name returns the reference without tag or digest.
+func (r imageReference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// digestFromImageID extracts the sha256 digest from a container status
+// ImageID, which may carry a docker-pullable:// prefix.
+func digestFromImageID(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i > -1 {
+		imageID = imageID[i+1:]
+	}
+	if strings.HasPrefix(imageID, "sha256:") && len(imageID) == len("sha256:")+64 {
+		return imageID
+	}
+	return ""
+}
+
+// registryCredentials are the basic-auth credentials for a registry host.
+type registryCredentials struct {
+	Username string
+	Password string
+}
+
+// credentialsFromPullSecret looks up the credentials for registry in a
+// .dockerconfigjson pull secret. It returns nil when none are found.
+func credentialsFromPullSecret(secret *corev1.Secret, registry string) *registryCredentials {
+	if secret == nil {
+		return nil
+	}
+	config := struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+		return nil
+	}
+	for host, entry := range config.Auths {
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+		host = strings.TrimSuffix(strings.SplitN(host, "/", 2)[0], "/")
+		if host != registry && !(registry == "docker.io" && host == "index.docker.io") {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil
+		}
+		username, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil
+		}
+		return &registryCredentials{Username: username, Password: password}
+	}
+	return nil
+}
+
+// registryClient is a minimal client for the registry v2 API, supporting
+// anonymous, basic and bearer token authentication.
+type registryClient struct {
+	client      *http.Client
+	registry    string
+	credentials *registryCredentials
+	token       string
+}
+
+func (c *registryClient) baseURL() string {
+	if c.registry == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	return "https://" + c.registry
+}
+
+// get fetches path from the registry, authenticating if challenged.
+func (c *registryClient) get(ctx context.Context, path string, accept ...string) ([]byte, error) {
+	resp, err := c.do(ctx, path, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(ctx, path, accept); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", path, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxRegistryResponseBytes))
+}
+
+func (c *registryClient) do(ctx context.Context, path string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accept {
+		req.Header.Add("Accept", a)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.credentials != nil {
+		req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+	return c.client.Do(req)
+}
+
+// authenticate answers a Bearer WWW-Authenticate challenge by requesting a
+// token from the advertised realm.
+func (c *registryClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry %s rejected the request with challenge %q", c.registry, challenge)
+	}
+	values := parseChallengeParams(params)
+	realm, err := url.Parse(values["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("registry %s returned an invalid token realm %q", c.registry, values["realm"])
+	}
+	query := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if values[k] != "" {
+			query.Set(k, values[k])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.credentials != nil {
+		req.SetBasicAuth(c.credentials.Username, c.credentials.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting a token from %s returned %s", realm.Host, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRegistryResponseBytes)).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode token from %s: %w", realm.Host, err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("token response from %s did not include a token", realm.Host)
+	}
+	return nil
+}
+
+// parseChallengeParams parses the comma-separated key="value" parameters of a
+// WWW-Authenticate challenge. Quoted values may contain commas.
+func parseChallengeParams(params string) map[string]string {
+	values := map[string]string{}
+	for params != "" {
+		key, rest, ok := strings.Cut(strings.TrimLeft(params, ", "), "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = value
+		params = rest
+	}
+	return values
+}
+
+// simpleSigningPayload is the simple-signing document signed by cosign and by
+// GPG simple signatures.
+type simpleSigningPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// ociManifest holds the parts of an image manifest needed to find signatures.
+type ociManifest struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// verifyCosignSignature checks that at least one of the cosign signatures
+// attached to ref's repository for digest was made by publicKey.
+func verifyCosignSignature(ctx context.Context, c *registryClient, ref imageReference, digest string, publicKey crypto.PublicKey) error {
+	tag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	body, err := c.get(ctx, fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository, tag),
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json")
+	if err != nil {
+		return fmt.Errorf("failed to fetch signatures for %s@%s: %w", ref.Name(), digest, err)
+	}
+	manifest := ociManifest{}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return fmt.Errorf("failed to decode signature manifest for %s@%s: %w", ref.Name(), digest, err)
+	}
+
+	var errs []error
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		if err := verifyCosignLayer(ctx, c, ref, digest, layer.Digest, encoded, publicKey); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no cosign signatures found for %s@%s", ref.Name(), digest)
+	}
+	return fmt.Errorf("no valid cosign signature found for %s@%s: %w", ref.Name(), digest, errors.Join(errs...))
+}
+
+func verifyCosignLayer(ctx context.Context, c *registryClient, ref imageReference, digest, layerDigest, encodedSignature string, publicKey crypto.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return fmt.Errorf("layer %s: failed to decode signature: %w", layerDigest, err)
+	}
+	payload, err := c.get(ctx, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, layerDigest))
+	if err != nil {
+		return fmt.Errorf("layer %s: %w", layerDigest, err)
+	}
+	sum := sha256.Sum256(payload)
+	if "sha256:"+hex.EncodeToString(sum[:]) != layerDigest {
+		return fmt.Errorf("layer %s: payload does not match its digest", layerDigest)
+	}
+	if err := verifySignature(publicKey, payload, signature); err != nil {
+		return fmt.Errorf("layer %s: %w", layerDigest, err)
+	}
+
+	doc := simpleSigningPayload{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return fmt.Errorf("layer %s: failed to decode payload: %w", layerDigest, err)
+	}
+	if doc.Critical.Type != cosignSignatureType {
+		return fmt.Errorf("layer %s: unexpected payload type %q", layerDigest, doc.Critical.Type)
+	}
+	if doc.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("layer %s: payload signs digest %q, not %q", layerDigest, doc.Critical.Image.DockerManifestDigest, digest)
+	}
+	if signed := doc.Critical.Identity.DockerReference; signed != "" && signed != ref.Name() {
+		return fmt.Errorf("layer %s: payload signs repository %q, not %q", layerDigest, signed, ref.Name())
+	}
+	return nil
+}
+
+// verifySignature verifies a signature over payload, made with the private
+// half of publicKey and a SHA-256 digest where the algorithm requires one.
+func verifySignature(publicKey crypto.PublicKey, payload, signature []byte) error {
+	digest := sha256.Sum256(payload)
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("invalid RSA signature: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return errors.New("invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
+
+// parsePublicKey parses a PEM-encoded PKIX public key.
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// registryHTTPClient returns a client trusting the system roots plus the
+// additional registry CA, if one is configured.
+func registryHTTPClient(resources *kubeResources) *http.Client {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if resources.trustedCAConfig != nil {
+		pool.AppendCertsFromPEM([]byte(resources.trustedCAConfig.Data[NameCertConfigMapKey]))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// ensureGraphDataSignature verifies the resolved graph-data digest against the
+// configured verification policy and pins the graph-data init container to the
+// digest that should be served. It returns the pinned pull spec, or
+// errRolloutHeld when there is no verified digest to roll out.
+func (r *UpdateServiceReconciler) ensureGraphDataSignature(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService,
+	resources *kubeResources, imageID string) (string, error) {
+
+	policy := instance.Spec.GraphDataImageVerification
+	ref, err := parseImageReference(instance.Spec.GraphDataImage)
+	if err != nil {
+		return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "InvalidGraphDataImage", err)
+	}
+	digest := ref.Digest
+	if digest == "" {
+		digest = digestFromImageID(imageID)
+	}
+	if digest == "" {
+		return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "DigestNotResolved",
+			fmt.Errorf("the digest of graph-data image %q has not been resolved yet", instance.Spec.GraphDataImage))
+	}
+
+	keyConfigMap := &corev1.ConfigMap{}
+	err = r.apiReader().Get(ctx, types.NamespacedName{Name: policy.PublicKey.Name, Namespace: instance.Namespace}, keyConfigMap)
+	if err != nil {
+		return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "PublicKeyNotFound", err)
+	}
+	keyData, ok := keyConfigMap.Data[policy.PublicKey.Key]
+	if !ok {
+		return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "PublicKeyNotFound",
+			fmt.Errorf("key %q not found in ConfigMap %s/%s", policy.PublicKey.Key, instance.Namespace, policy.PublicKey.Name))
+	}
+	var verify func(*registryClient) error
+	switch policy.Type {
+	case cv1.SignatureTypeCosign:
+		publicKey, err := parsePublicKey([]byte(keyData))
+		if err != nil {
+			return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "InvalidPublicKey", err)
+		}
+		verify = func(c *registryClient) error {
+			return verifyCosignSignature(ctx, c, ref, digest, publicKey)
+		}
+	case cv1.SignatureTypeSimpleSigning:
+		keyring, err := parseKeyring([]byte(keyData))
+		if err != nil {
+			return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "InvalidPublicKey", err)
+		}
+		verify = func(c *registryClient) error {
+			return verifySimpleSigningSignature(ctx, c, policy.SignatureStore, ref, digest, keyring)
+		}
+	default:
+		return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "VerificationFailed",
+			fmt.Errorf("unsupported signature type %q", policy.Type))
+	}
+
+	httpClient := r.registryHTTP
+	if httpClient == nil {
+		httpClient = registryHTTPClient(resources)
+	}
+	err = verify(&registryClient{
+		client:      httpClient,
+		registry:    ref.Registry,
+		credentials: credentialsFromPullSecret(resources.pullSecret, ref.Registry),
+	})
+	if err != nil {
+		return r.holdGraphDataImage(ctx, reqLogger, instance, resources, "VerificationFailed", err)
+	}
+
+	pullSpec := ref.Name() + "@" + digest
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+		Type:    cv1.ConditionGraphDataSignatureVerified,
+		Status:  corev1.ConditionTrue,
+		Reason:  "Verified",
+		Message: fmt.Sprintf("Verified the signature of %s", pullSpec),
+	})
+	resources.pinGraphDataImage(pullSpec)
+	return graphDataAnnotation(imageID, digest), nil
+}
+
+// graphDataAnnotation returns the graph-data image annotation value for
+// digest. It keeps imageID when that already resolves to digest, so that
+// verifying the image does not change the Deployment template, and falls
+// back to the bare digest otherwise.
+func graphDataAnnotation(imageID, digest string) string {
+	if digestFromImageID(imageID) == digest {
+		return imageID
+	}
+	return digest
+}
+
+// holdGraphDataImage records a verification failure and pins the graph-data
+// init container to the image the existing Deployment already serves.
+func (r *UpdateServiceReconciler) holdGraphDataImage(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService,
+	resources *kubeResources, reason string, cause error) (string, error) {
+
+	current, annotation, err := r.servedGraphDataImage(ctx, resources)
+	if err != nil {
+		return "", err
+	}
+	message := cause.Error()
+	if current != "" {
+		message = fmt.Sprintf("%s; continuing to serve %s", message, current)
+	} else {
+		message = fmt.Sprintf("%s; holding the rollout until a verified image is available", message)
+	}
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
+		Type:    cv1.ConditionGraphDataSignatureVerified,
+		Status:  corev1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	reqLogger.Error(cause, "Graph-data image verification failed", "Reason", reason)
+	if current == "" {
+		return "", errRolloutHeld
+	}
+	resources.pinGraphDataImage(current)
+	return graphDataAnnotation(annotation, digestFromImageID(current)), nil
+}
+
+// servedGraphDataImage returns the by-digest graph-data image of the existing
+// Deployment along with its graph-data image annotation, or empty strings if
+// it does not exist or is not pinned.
+func (r *UpdateServiceReconciler) servedGraphDataImage(ctx context.Context, resources *kubeResources) (string, string, error) {
+	found := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: resources.deployment.Name, Namespace: resources.deployment.Namespace}, found)
+	if err != nil {
+		if apiErrors.IsNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	for _, c := range found.Spec.Template.Spec.InitContainers {
+		if c.Name == NameInitContainerGraphData && strings.Contains(c.Image, "@sha256:") {
+			return c.Image, found.Spec.Template.Annotations[GraphDataImageAnnotation], nil
+		}
+	}
+	return "", "", nil
+}
+
+// pinGraphDataImage points the graph-data init container at pullSpec.
+func (k *kubeResources) pinGraphDataImage(pullSpec string) {
+	k.graphDataInitContainer.Image = pullSpec
+	for i := range k.deployment.Spec.Template.Spec.InitContainers {
+		if k.deployment.Spec.Template.Spec.InitContainers[i].Name == NameInitContainerGraphData {
+			k.deployment.Spec.Template.Spec.InitContainers[i].Image = pullSpec
+		}
+	}
+}
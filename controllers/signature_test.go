@@ -0,0 +1,437 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	testRepository  = "cincinnati/graph-data"
+	testDigest      = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testOtherDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	testKeyName     = "graph-data-key"
+)
+
+// testRegistry is a stand-in registry serving cosign signatures for one
+// repository, and its simple signatures through the registry signature API
+// and a lookaside store under /sigstore.
+type testRegistry struct {
+	server     *httptest.Server
+	signatures map[string][]testSignature
+	token      string
+	// simpleSignatures are the simple signatures of each digest.
+	simpleSignatures map[string][][]byte
+}
+
+type testSignature struct {
+	payload   []byte
+	signature []byte
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{signatures: map[string][]testSignature{}, simpleSignatures: map[string][][]byte{}}
+	blobs := map[string][]byte{}
+	reg.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"token": reg.token})
+			return
+		}
+		if reg.token != "" && req.Header.Get("Authorization") != "Bearer "+reg.token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:%s:pull"`, reg.server.URL, testRepository))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		manifestPrefix := "/v2/" + testRepository + "/manifests/"
+		blobPrefix := "/v2/" + testRepository + "/blobs/"
+		signaturesPrefix := "/extensions/v2/" + testRepository + "/signatures/"
+		lookasidePrefix := "/sigstore/" + testRepository + "@"
+		switch {
+		case strings.HasPrefix(req.URL.Path, signaturesPrefix):
+			var signatures []map[string]interface{}
+			for _, signature := range reg.simpleSignatures[strings.TrimPrefix(req.URL.Path, signaturesPrefix)] {
+				signatures = append(signatures, map[string]interface{}{
+					"version": 2,
+					"type":    "atomic",
+					"content": base64.StdEncoding.EncodeToString(signature),
+				})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"signatures": signatures})
+		case strings.HasPrefix(req.URL.Path, lookasidePrefix):
+			digest, name, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, lookasidePrefix), "/")
+			var n int
+			if _, err := fmt.Sscanf(name, "signature-%d", &n); err != nil {
+				http.NotFound(w, req)
+				return
+			}
+			signatures := reg.simpleSignatures[strings.Replace(digest, "=", ":", 1)]
+			if n < 1 || n > len(signatures) {
+				http.NotFound(w, req)
+				return
+			}
+			_, _ = w.Write(signatures[n-1])
+		case strings.HasPrefix(req.URL.Path, manifestPrefix):
+			tag := strings.TrimPrefix(req.URL.Path, manifestPrefix)
+			sigs, ok := reg.signatures[tag]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			manifest := map[string]interface{}{"schemaVersion": 2}
+			var layers []map[string]interface{}
+			for _, sig := range sigs {
+				sum := sha256.Sum256(sig.payload)
+				digest := "sha256:" + hex.EncodeToString(sum[:])
+				blobs[digest] = sig.payload
+				layers = append(layers, map[string]interface{}{
+					"digest": digest,
+					"annotations": map[string]string{
+						cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig.signature),
+					},
+				})
+			}
+			manifest["layers"] = layers
+			_ = json.NewEncoder(w).Encode(manifest)
+		case strings.HasPrefix(req.URL.Path, blobPrefix):
+			blob, ok := blobs[strings.TrimPrefix(req.URL.Path, blobPrefix)]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			_, _ = w.Write(blob)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(reg.server.Close)
+	return reg
+}
+
+func (reg *testRegistry) host() string {
+	return strings.TrimPrefix(reg.server.URL, "https://")
+}
+
+// sign attaches a cosign signature by key over a payload for signedDigest to digest.
+func (reg *testRegistry) sign(t *testing.T, key *ecdsa.PrivateKey, digest, signedDigest string) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"critical": map[string]interface{}{
+			"identity": map[string]string{"docker-reference": reg.host() + "/" + testRepository},
+			"image":    map[string]string{"docker-manifest-digest": signedDigest},
+			"type":     cosignSignatureType,
+		},
+		"optional": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	reg.signatures[tag] = append(reg.signatures[tag], testSignature{payload: payload, signature: signature})
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func Test_parseImageReference(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		pullSpec string
+		expected imageReference
+		err      bool
+	}{
+		{
+			name:     "tag",
+			pullSpec: "quay.io/cincinnati/graph-data:latest",
+			expected: imageReference{Registry: "quay.io", Repository: "cincinnati/graph-data", Tag: "latest"},
+		},
+		{
+			name:     "digest",
+			pullSpec: "quay.io/cincinnati/graph-data@" + testDigest,
+			expected: imageReference{Registry: "quay.io", Repository: "cincinnati/graph-data", Digest: testDigest},
+		},
+		{
+			name:     "registry with port",
+			pullSpec: "registry.example.com:5000/graph-data:v1",
+			expected: imageReference{Registry: "registry.example.com:5000", Repository: "graph-data", Tag: "v1"},
+		},
+		{
+			name:     "docker hub short name",
+			pullSpec: "graph-data",
+			expected: imageReference{Registry: "docker.io", Repository: "library/graph-data"},
+		},
+		{
+			name:     "invalid digest",
+			pullSpec: "quay.io/cincinnati/graph-data@sha256:123",
+			err:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := parseImageReference(tc.pullSpec)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, ref)
+		})
+	}
+}
+
+func Test_parseChallengeParams(t *testing.T) {
+	params := parseChallengeParams(`realm="https://auth.example.com/token",service="registry",scope="repository:a/b:pull,push"`)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry",
+		"scope":   "repository:a/b:pull,push",
+	}, params)
+}
+
+func Test_verifyCosignSignature(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+	for _, tc := range []struct {
+		name   string
+		setup  func(reg *testRegistry)
+		errMsg string
+	}{
+		{
+			name:  "valid signature",
+			setup: func(reg *testRegistry) { reg.sign(t, key, testDigest, testDigest) },
+		},
+		{
+			name: "valid signature behind a bearer token",
+			setup: func(reg *testRegistry) {
+				reg.token = "secret"
+				reg.sign(t, key, testDigest, testDigest)
+			},
+		},
+		{
+			name: "one of several signatures is valid",
+			setup: func(reg *testRegistry) {
+				reg.sign(t, otherKey, testDigest, testDigest)
+				reg.sign(t, key, testDigest, testDigest)
+			},
+		},
+		{
+			name:   "no signature",
+			setup:  func(reg *testRegistry) {},
+			errMsg: "404 Not Found",
+		},
+		{
+			name:   "signed by another key",
+			setup:  func(reg *testRegistry) { reg.sign(t, otherKey, testDigest, testDigest) },
+			errMsg: "invalid ECDSA signature",
+		},
+		{
+			name:   "payload signs another digest",
+			setup:  func(reg *testRegistry) { reg.sign(t, key, testDigest, testOtherDigest) },
+			errMsg: "payload signs digest",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := newTestRegistry(t)
+			tc.setup(reg)
+			ref, err := parseImageReference(reg.host() + "/" + testRepository + ":latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &registryClient{client: reg.server.Client(), registry: ref.Registry}
+			err = verifyCosignSignature(context.TODO(), c, ref, testDigest, &key.PublicKey)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func Test_credentialsFromPullSecret(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("user:pass")) + `"}}}`),
+		},
+	}
+	assert.Equal(t, &registryCredentials{Username: "user", Password: "pass"}, credentialsFromPullSecret(secret, "quay.io"))
+	assert.Nil(t, credentialsFromPullSecret(secret, "registry.example.com"))
+	assert.Nil(t, credentialsFromPullSecret(nil, "quay.io"))
+}
+
+func TestEnsureGraphDataSignature(t *testing.T) {
+	key := newTestKey(t)
+	reg := newTestRegistry(t)
+	reg.sign(t, key, testDigest, testDigest)
+	image := reg.host() + "/" + testRepository
+
+	keyConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: testKeyName, Namespace: testNamespace},
+		Data:       map[string]string{"key.pub": encodePublicKey(t, &key.PublicKey)},
+	}
+	newVerifiedUpdateService := func() *cv1.UpdateService {
+		us := newDefaultUpdateService()
+		us.Spec.GraphDataImage = image + ":latest"
+		us.Spec.GraphDataImageVerification = &cv1.GraphDataImageVerification{
+			Type:      cv1.SignatureTypeCosign,
+			PublicKey: cv1.ConfigMapKeyReference{Name: testKeyName, Key: "key.pub"},
+		}
+		return us
+	}
+	servedDeployment := func(us *cv1.UpdateService, pullSpec string, annotation string) *appsv1.Deployment {
+		resources, err := newKubeResources(us, testOperandImage, testNamespace, nil, newSecret(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources.pinGraphDataImage(pullSpec)
+		if annotation != "" {
+			resources.deployment.Spec.Template.Annotations = map[string]string{GraphDataImageAnnotation: annotation}
+		}
+		return resources.deployment
+	}
+
+	for _, tc := range []struct {
+		name               string
+		imageID            string
+		existingObjs       func(us *cv1.UpdateService) []runtime.Object
+		expectedImage      string
+		expectedAnnotation string
+		expectedErr        error
+		expectedStatus     corev1.ConditionStatus
+		expectedReason     string
+	}{
+		{
+			name:    "verified digest is pinned",
+			imageID: image + "@" + testDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, keyConfigMap}
+			},
+			expectedImage:      image + "@" + testDigest,
+			expectedAnnotation: image + "@" + testDigest,
+			expectedStatus:     corev1.ConditionTrue,
+			expectedReason:     "Verified",
+		},
+		{
+			name:    "verified digest keeps the image ID annotation",
+			imageID: "docker-pullable://mirror.example.com/graph-data@" + testDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, keyConfigMap}
+			},
+			expectedImage:      image + "@" + testDigest,
+			expectedAnnotation: "docker-pullable://mirror.example.com/graph-data@" + testDigest,
+			expectedStatus:     corev1.ConditionTrue,
+			expectedReason:     "Verified",
+		},
+		{
+			name:    "unsigned digest keeps serving the previous digest",
+			imageID: image + "@" + testOtherDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, keyConfigMap, servedDeployment(us, image+"@"+testDigest, "")}
+			},
+			expectedImage:      image + "@" + testDigest,
+			expectedAnnotation: testDigest,
+			expectedStatus:     corev1.ConditionFalse,
+			expectedReason:     "VerificationFailed",
+		},
+		{
+			name:    "unsigned digest keeps the previous annotation",
+			imageID: image + "@" + testOtherDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, keyConfigMap, servedDeployment(us, image+"@"+testDigest, "docker-pullable://mirror.example.com/graph-data@"+testDigest)}
+			},
+			expectedImage:      image + "@" + testDigest,
+			expectedAnnotation: "docker-pullable://mirror.example.com/graph-data@" + testDigest,
+			expectedStatus:     corev1.ConditionFalse,
+			expectedReason:     "VerificationFailed",
+		},
+		{
+			name:    "unsigned digest without a previous digest holds the rollout",
+			imageID: image + "@" + testOtherDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, keyConfigMap}
+			},
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "VerificationFailed",
+		},
+		{
+			name:    "missing public key",
+			imageID: image + "@" + testDigest,
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us}
+			},
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "PublicKeyNotFound",
+		},
+		{
+			name: "unresolved digest",
+			existingObjs: func(us *cv1.UpdateService) []runtime.Object {
+				return []runtime.Object{us, keyConfigMap}
+			},
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "DigestNotResolved",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			us := newVerifiedUpdateService()
+			r := newTestReconciler(tc.existingObjs(us)...)
+			r.registryHTTP = reg.server.Client()
+			resources, err := newKubeResources(us, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			annotation, err := r.ensureGraphDataSignature(context.TODO(), log, us, resources, tc.imageID)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedAnnotation, annotation)
+			if tc.expectedImage != "" {
+				assert.Equal(t, tc.expectedImage, resources.graphDataInitContainer.Image)
+				assert.Equal(t, tc.expectedImage, resources.deployment.Spec.Template.Spec.InitContainers[0].Image)
+			}
+			condition := conditionsv1.FindStatusCondition(us.Status.Conditions, cv1.ConditionGraphDataSignatureVerified)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedStatus, condition.Status)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+			}
+		})
+	}
+}
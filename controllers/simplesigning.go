@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	// simpleSigningSignatureType is the critical.type of a simple-signing
+	// payload signed with GPG.
+	simpleSigningSignatureType = "atomic container signature"
+
+	// maxSimpleSignatures bounds how many signatures of a digest are read
+	// from a signature store.
+	maxSimpleSignatures = 16
+)
+
+// registrySignatures is the response of the registry signature API.
+type registrySignatures struct {
+	Signatures []struct {
+		Version int    `json:"version"`
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	} `json:"signatures"`
+}
+
+// parseKeyring parses an ASCII-armored or binary GPG public keyring.
+func parseKeyring(data []byte) (openpgp.EntityList, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		if keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to read GPG keyring: %w", err)
+		}
+	}
+	if len(keyring) == 0 {
+		return nil, errors.New("no GPG keys found")
+	}
+	return keyring, nil
+}
+
+// verifySimpleSigningSignature checks that at least one of the simple-signing
+// signatures of ref's digest was made by a key of keyring. The signatures
+// are read from the lookaside store at store, or else from the registry
+// signature API.
+func verifySimpleSigningSignature(ctx context.Context, c *registryClient, store string, ref imageReference, digest string, keyring openpgp.EntityList) error {
+	var signatures [][]byte
+	var err error
+	if store != "" {
+		signatures, err = fetchLookasideSignatures(ctx, c.client, store, ref, digest)
+	} else {
+		signatures, err = fetchRegistrySignatures(ctx, c, ref, digest)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch signatures for %s@%s: %w", ref.Name(), digest, err)
+	}
+
+	var errs []error
+	for i, signature := range signatures {
+		if err := verifySimpleSignature(signature, keyring, ref, digest); err != nil {
+			errs = append(errs, fmt.Errorf("signature %d: %w", i+1, err))
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no simple-signing signatures found for %s@%s", ref.Name(), digest)
+	}
+	return fmt.Errorf("no valid simple-signing signature found for %s@%s: %w", ref.Name(), digest, errors.Join(errs...))
+}
+
+// fetchRegistrySignatures reads the signatures of digest from the signature
+// API which the OpenShift image registry serves.
+func fetchRegistrySignatures(ctx context.Context, c *registryClient, ref imageReference, digest string) ([][]byte, error) {
+	body, err := c.get(ctx, fmt.Sprintf("/extensions/v2/%s/signatures/%s", ref.Repository, digest), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	response := registrySignatures{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode signatures: %w", err)
+	}
+	var signatures [][]byte
+	for _, s := range response.Signatures {
+		if s.Type != "atomic" {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(s.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature: %w", err)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// fetchLookasideSignatures reads the signatures of digest from a lookaside
+// store, which serves them as <store>/<repository>@sha256=<hex>/signature-<n>
+// for n from 1 until the first missing one.
+func fetchLookasideSignatures(ctx context.Context, client *http.Client, store string, ref imageReference, digest string) ([][]byte, error) {
+	base := fmt.Sprintf("%s/%s@%s", strings.TrimSuffix(store, "/"), ref.Repository, strings.Replace(digest, ":", "=", 1))
+	var signatures [][]byte
+	for n := 1; n <= maxSimpleSignatures; n++ {
+		url := fmt.Sprintf("%s/signature-%d", base, n)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			break
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+		}
+		signature, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistryResponseBytes))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// verifySimpleSignature checks that signature is a GPG-signed simple-signing
+// payload, made by a key of keyring, for digest in ref's repository.
+func verifySimpleSignature(signature []byte, keyring openpgp.EntityList, ref imageReference, digest string) error {
+	md, err := openpgp.ReadMessage(bytes.NewReader(signature), keyring, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+	if !md.IsSigned {
+		return errors.New("the message is not signed")
+	}
+	// the signature is only checked once the payload was read
+	payload, err := io.ReadAll(io.LimitReader(md.UnverifiedBody, maxRegistryResponseBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read payload: %w", err)
+	}
+	if md.SignedBy == nil {
+		return fmt.Errorf("signed by unknown key %X", md.SignedByKeyId)
+	}
+	if md.SignatureError != nil {
+		return fmt.Errorf("invalid GPG signature: %w", md.SignatureError)
+	}
+
+	doc := simpleSigningPayload{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+	if doc.Critical.Type != simpleSigningSignatureType {
+		return fmt.Errorf("unexpected payload type %q", doc.Critical.Type)
+	}
+	if doc.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("payload signs digest %q, not %q", doc.Critical.Image.DockerManifestDigest, digest)
+	}
+	signed, err := parseImageReference(doc.Critical.Identity.DockerReference)
+	if err != nil || signed.Name() != ref.Name() {
+		return fmt.Errorf("payload signs image %q, not one of %q", doc.Critical.Identity.DockerReference, ref.Name())
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+func newTestGPGEntity(t *testing.T) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("Graph Data", "", "graph-data@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without preferred hashes, openpgp.Sign falls back to RIPEMD-160
+	for _, identity := range entity.Identities {
+		identity.SelfSignature.PreferredHash = []uint8{8} // SHA-256
+	}
+	return entity
+}
+
+// encodeKeyring returns the ASCII-armored public keyring of entity.
+func encodeKeyring(t *testing.T, entity *openpgp.Entity) string {
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// simpleSign returns a simple signature by entity of digest for reference.
+func simpleSign(t *testing.T, entity *openpgp.Entity, reference, digest string) []byte {
+	payload, err := json.Marshal(map[string]interface{}{
+		"critical": map[string]interface{}{
+			"identity": map[string]string{"docker-reference": reference},
+			"image":    map[string]string{"docker-manifest-digest": digest},
+			"type":     simpleSigningSignatureType,
+		},
+		"optional": map[string]interface{}{"creator": "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w, err := openpgp.Sign(buf, entity, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_parseKeyring(t *testing.T) {
+	entity := newTestGPGEntity(t)
+	keyring, err := parseKeyring([]byte(encodeKeyring(t, entity)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, entity.PrimaryKey.KeyId, keyring[0].PrimaryKey.KeyId)
+	}
+
+	binary := &bytes.Buffer{}
+	if err := entity.Serialize(binary); err != nil {
+		t.Fatal(err)
+	}
+	_, err = parseKeyring(binary.Bytes())
+	assert.NoError(t, err)
+
+	_, err = parseKeyring([]byte("not a keyring"))
+	assert.Error(t, err)
+}
+
+func TestEnsureGraphDataSimpleSigning(t *testing.T) {
+	signer := newTestGPGEntity(t)
+	other := newTestGPGEntity(t)
+	reg := newTestRegistry(t)
+	image := reg.host() + "/" + testRepository
+
+	for _, tc := range []struct {
+		name           string
+		signatures     [][]byte
+		keyring        string
+		store          bool
+		expectedErr    error
+		expectedStatus corev1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "verified with the registry signature API",
+			signatures:     [][]byte{simpleSign(t, signer, image+":latest", testDigest)},
+			keyring:        encodeKeyring(t, signer),
+			expectedStatus: corev1.ConditionTrue,
+			expectedReason: "Verified",
+		},
+		{
+			name:           "verified with a lookaside store",
+			signatures:     [][]byte{simpleSign(t, other, image+":latest", testDigest), simpleSign(t, signer, image+":latest", testDigest)},
+			keyring:        encodeKeyring(t, signer),
+			store:          true,
+			expectedStatus: corev1.ConditionTrue,
+			expectedReason: "Verified",
+		},
+		{
+			name:           "signed by another key",
+			signatures:     [][]byte{simpleSign(t, other, image+":latest", testDigest)},
+			keyring:        encodeKeyring(t, signer),
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "VerificationFailed",
+		},
+		{
+			name:           "signature of another digest",
+			signatures:     [][]byte{simpleSign(t, signer, image+":latest", testOtherDigest)},
+			keyring:        encodeKeyring(t, signer),
+			store:          true,
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "VerificationFailed",
+		},
+		{
+			name:           "signature of another repository",
+			signatures:     [][]byte{simpleSign(t, signer, reg.host()+"/cincinnati/other:latest", testDigest)},
+			keyring:        encodeKeyring(t, signer),
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "VerificationFailed",
+		},
+		{
+			name:           "unsigned",
+			keyring:        encodeKeyring(t, signer),
+			store:          true,
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "VerificationFailed",
+		},
+		{
+			name:           "invalid keyring",
+			signatures:     [][]byte{simpleSign(t, signer, image+":latest", testDigest)},
+			keyring:        "not a keyring",
+			expectedErr:    errRolloutHeld,
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: "InvalidPublicKey",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg.simpleSignatures[testDigest] = tc.signatures
+			us := newDefaultUpdateService()
+			us.Spec.GraphDataImage = image + ":latest"
+			us.Spec.GraphDataImageVerification = &cv1.GraphDataImageVerification{
+				Type:      cv1.SignatureTypeSimpleSigning,
+				PublicKey: cv1.ConfigMapKeyReference{Name: testKeyName, Key: "pubring.gpg"},
+			}
+			if tc.store {
+				us.Spec.GraphDataImageVerification.SignatureStore = reg.server.URL + "/sigstore"
+			}
+			keyConfigMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: testKeyName, Namespace: testNamespace},
+				Data:       map[string]string{"pubring.gpg": tc.keyring},
+			}
+			r := newTestReconciler(us, keyConfigMap)
+			r.registryHTTP = reg.server.Client()
+			resources, err := newKubeResources(us, testOperandImage, testNamespace, nil, newSecret(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			annotation, err := r.ensureGraphDataSignature(context.TODO(), log, us, resources, image+"@"+testDigest)
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr == nil {
+				assert.Equal(t, image+"@"+testDigest, annotation)
+				assert.Equal(t, image+"@"+testDigest, resources.graphDataInitContainer.Image)
+			}
+			condition := conditionsv1.FindStatusCondition(us.Status.Conditions, cv1.ConditionGraphDataSignatureVerified)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, tc.expectedStatus, condition.Status)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cv1 "github.com/openshift/cincinnati-operator/api/v1"
+)
+
+const (
+	tracerName = "github.com/openshift/cincinnati-operator/controllers"
+
+	// Span attributes of the reconcile steps.
+	attrUpdated           = attribute.Key("updateservice.updated")
+	attrResourceKind      = attribute.Key("updateservice.resource.kind")
+	attrResourceNamespace = attribute.Key("updateservice.resource.namespace")
+	attrResourceName      = attribute.Key("updateservice.resource.name")
+)
+
+// NewTracerProvider returns a TracerProvider exporting spans over OTLP/gRPC,
+// when the OTEL_TRACES_EXPORTER environment variable is "otlp" and the SDK is
+// not disabled with OTEL_SDK_DISABLED. The exporter, sampler and resource are
+// configured by the other standard OTEL_* variables. Otherwise it returns nil,
+// and tracing is disabled.
+func NewTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return nil, nil
+	}
+	if strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")) != "otlp" {
+		return nil, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(operatorName)),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the above
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+// tracer returns the tracer of the reconciler.
+func (r *UpdateServiceReconciler) tracer() trace.Tracer {
+	provider := r.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// startStep starts the span of a reconcile step. Resource changes within the
+// step set its updated attribute.
+func (r *UpdateServiceReconciler) startStep(ctx context.Context, name string) (context.Context, trace.Span) {
+	return r.tracer().Start(ctx, name, trace.WithAttributes(attrUpdated.Bool(false)))
+}
+
+// traced wraps an ensure function in a span of the given name.
+func (r *UpdateServiceReconciler) traced(name string, f func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error) func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error {
+	return func(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+		ctx, span := r.startStep(ctx, name)
+		err := f(ctx, reqLogger, instance, resources)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceResourceChange marks the current span as updated by a change of obj,
+// and adds an event for the change.
+func traceResourceChange(ctx context.Context, kind string, obj client.Object, action string) {
+	span := trace.SpanFromContext(ctx)
+	resource := []attribute.KeyValue{
+		attrResourceKind.String(kind),
+		attrResourceNamespace.String(obj.GetNamespace()),
+		attrResourceName.String(obj.GetName()),
+	}
+	span.SetAttributes(append(resource, attrUpdated.Bool(true))...)
+	span.AddEvent(action, trace.WithAttributes(resource...))
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanAttributes returns the attributes of a span by key.
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestReconcileTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler(updateservice, newSecret())
+	r.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	spans := func() map[string]tracetest.SpanStub {
+		byName := map[string]tracetest.SpanStub{}
+		for _, span := range exporter.GetSpans() {
+			byName[span.Name] = span
+		}
+		exporter.Reset()
+		return byName
+	}
+
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+	first := spans()
+	reconcile, ok := first["Reconcile"]
+	if !ok {
+		t.Fatal("no Reconcile span")
+	}
+	assert.Equal(t, "foo", spanAttributes(reconcile)["updateservice.name"].AsString())
+	for _, name := range []string{"ensureConfig", "ensurePolicyEngineService", "ensureGraphDataSHA", "ensureDeployment"} {
+		step, ok := first[name]
+		if !assert.True(t, ok, "no %s span", name) {
+			continue
+		}
+		assert.Equal(t, reconcile.SpanContext.SpanID(), step.Parent.SpanID(), "%s is not a child of Reconcile", name)
+	}
+
+	deployment := spanAttributes(first["ensureDeployment"])
+	assert.True(t, deployment[attrUpdated].AsBool())
+	assert.Equal(t, "Deployment", deployment[attrResourceKind].AsString())
+	assert.Equal(t, "foo", deployment[attrResourceName].AsString())
+	assert.Equal(t, "Created", first["ensureDeployment"].Events[0].Name)
+
+	// nothing changes on the second reconcile
+	if _, err := r.Reconcile(context.TODO(), newRequest(updateservice)); err != nil {
+		t.Fatal(err)
+	}
+	second := spans()
+	assert.False(t, spanAttributes(second["ensureDeployment"])[attrUpdated].AsBool())
+	assert.Equal(t, codes.Unset, second["Reconcile"].Status.Code)
+}
+
+func TestNewTracerProviderDisabled(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		exporter string
+		disabled string
+	}{
+		{name: "by default"},
+		{name: "without the otlp exporter", exporter: "none"},
+		{name: "SDK disabled", exporter: "otlp", disabled: "true"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("OTEL_TRACES_EXPORTER", tc.exporter)
+			t.Setenv("OTEL_SDK_DISABLED", tc.disabled)
+			provider, err := NewTracerProvider(context.TODO())
+			assert.NoError(t, err)
+			assert.Nil(t, provider)
+		})
+	}
+}
@@ -4,27 +4,40 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"reflect"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apiErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	apicfgv1 "github.com/openshift/api/config/v1"
 	routev1 "github.com/openshift/api/route/v1"
@@ -52,25 +65,76 @@ type UpdateServiceReconciler struct {
 	Log               logr.Logger
 	OperandImage      string
 	OperatorNamespace string
+
+	// WatchNamespaces, if set, are the namespaces whose UpdateServices are
+	// reconciled, with cache.AllNamespaces for all of them. Only the
+	// UpdateServices in OperatorNamespace are reconciled otherwise.
+	WatchNamespaces []string
+
+	// Recorder, if set, records events on UpdateServices for the changes
+	// and failures of each reconcile.
+	Recorder record.EventRecorder
+
+	// APIReader, if set, reads the objects which the manager does not
+	// cache, such as the ConfigMaps in openshift-config. Client is used
+	// otherwise.
+	APIReader client.Reader
+
+	// registryHTTP, if set, replaces the client used to fetch graph-data
+	// image signatures from the registry.
+	registryHTTP *http.Client
+
+	// operandHTTP, if set, replaces the client used to query the operand's
+	// Services.
+	operandHTTP *http.Client
+
+	// clock, if set, replaces time.Now when evaluating rollout schedules.
+	clock func() time.Time
+
+	// TracerProvider, if set, replaces the global TracerProvider for the
+	// spans of each reconcile.
+	TracerProvider trace.TracerProvider
+
+	// limiter bounds the concurrent reconciles by the configured
+	// maxConcurrentReconciles.
+	limiter reconcileLimiter
+
+	// renderings are the renderings this process applied, see
+	// renderingApplied.
+	renderings appliedRenderings
+
+	// lastReconciled is when the latest reconcile returned, in Unix
+	// nanoseconds, for LivenessCheck.
+	lastReconciled atomic.Int64
 }
 
 // +kubebuilder:rbac:groups="",resources=configmaps;pods;services;secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch
 // +kubebuilder:rbac:groups="policy",resources=poddisruptionbudgets,verbs=get;list;watch
-// +kubebuilder:rbac:groups=config.openshift.io,resources=images,verbs=get;list;watch
+// +kubebuilder:rbac:groups=config.openshift.io,resources=images;proxies,verbs=get;list;watch
 // +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch
 // +kubebuilder:rbac:groups=updateservice.operator.openshift.io,resources=*,verbs=get;list;watch
+// +kubebuilder:rbac:groups=console.openshift.io,resources=consolelinks,verbs=create;delete;deletecollection;get;list;patch;update;watch
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+// The permissions in openshift-update-service are granted in the other watched
+// namespaces by config/rbac/watch-namespace, which has the same rules.
 // +kubebuilder:rbac:groups="",resources=pods;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
 // +kubebuilder:rbac:groups="apps",resourceNames=updateservice-operator,resources=deployments/finalizers,verbs=update,namespace=openshift-update-service
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=create;get;update,namespace=openshift-update-service
 // +kubebuilder:rbac:groups="apps",resources=deployments;daemonsets;replicasets;statefulsets,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
-// +kubebuilder:rbac:groups="monitoring.coreos.com",resources=servicemonitors,verbs=create;get,namespace=openshift-update-service
+// +kubebuilder:rbac:groups="monitoring.coreos.com",resources=servicemonitors;prometheusrules,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
 // +kubebuilder:rbac:groups="policy",resources=poddisruptionbudgets,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
-// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=create;get;list;patch;update;watch,namespace=openshift-update-service
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
 // +kubebuilder:rbac:groups=updateservice.operator.openshift.io,resources=*,verbs=create;delete;get;list;patch;update;watch,namespace=openshift-update-service
+// The console dashboard is the only resource written in openshift-config-managed.
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create,namespace=openshift-config-managed
+// +kubebuilder:rbac:groups="",resources=configmaps,resourceNames=grafana-dashboard-openshift-update-service,verbs=delete;patch;update,namespace=openshift-config-managed
 
-func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	/*    **Reconcile Pattern**
 	      1. Gather conditions
 	      2. Create all the kubeResources
@@ -79,28 +143,74 @@ func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	reqLogger := log.WithValues("Request.Namespace", req.Namespace, "Request.Name", req.Name)
 	reqLogger.Info("Reconciling UpdateService")
+	defer r.reconcileCompleted()
 
-	if req.Namespace != r.OperatorNamespace {
-		reqLogger.Info(fmt.Sprintf("Ignoring reconcile request for resource outside of operator's namespace %s",
-			r.OperatorNamespace))
+	ctx, span := r.tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		attribute.String("updateservice.namespace", req.Namespace),
+		attribute.String("updateservice.name", req.Name),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if !r.watches(req.Namespace) {
+		reqLogger.Info(fmt.Sprintf("Ignoring reconcile request for resource outside of the watched namespaces %s",
+			strings.Join(r.watchedNamespaces(), ",")))
 		return ctrl.Result{}, nil
 	}
 
+	config, err := r.operatorConfig(ctx)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get the operator configuration")
+		return ctrl.Result{}, err
+	}
+	defer r.limiter.acquire(maxConcurrentReconciles(config))()
+
 	// Fetch the UpdateService instance
 	instance := &cv1.UpdateService{}
-	err := r.Client.Get(ctx, req.NamespacedName, instance)
+	err = r.Client.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
 		if apiErrors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Return and don't requeue
+			forgetMetrics(req.NamespacedName)
+			r.renderings.forget(req.NamespacedName)
+			if err := r.deleteConsoleLinks(ctx, req.NamespacedName); err != nil {
+				reqLogger.Error(err, "Failed to delete ConsoleLinks")
+				return ctrl.Result{}, err
+			}
+			if err := r.deleteConsoleDashboard(ctx, reqLogger); err != nil {
+				reqLogger.Error(err, "Failed to delete the console dashboard")
+				return ctrl.Result{}, err
+			}
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return ctrl.Result{}, err
 	}
 
+	reqLogger = reqLogger.WithValues("Request.UID", instance.UID)
+	span.SetAttributes(attribute.String("updateservice.uid", string(instance.UID)))
+	start := time.Now()
+	defer func() { observeReconcile(req.NamespacedName, start, err) }()
+
 	instanceCopy := instance.DeepCopy()
-	instanceCopy.Status = cv1.UpdateServiceStatus{}
+	if instance.Spec.ManagementState == cv1.ManagementStateUnmanaged {
+		// the resources may have been changed by hand, so their status is
+		// kept and updated rather than rebuilt, and they are applied again
+		// once Managed
+		conditionsv1.RemoveStatusCondition(&instanceCopy.Status.Conditions, cv1.ConditionReconcileError)
+		instanceCopy.Status.ResourceErrors = nil
+		instanceCopy.Status.LastAppliedHash = ""
+	} else {
+		instanceCopy.Status = cv1.UpdateServiceStatus{
+			GraphDataHistory: instance.Status.GraphDataHistory,
+			GraphServing:     instance.Status.GraphServing,
+			GraphBuilder:     instance.Status.GraphBuilder,
+			SchemaVersion:    instance.Status.SchemaVersion,
+		}
+	}
+	if instance.Spec.ManagementState == cv1.ManagementStateRemoved {
+		return r.reconcileRemoved(ctx, reqLogger, instance, instanceCopy)
+	}
 
 	if err := validateRouteName(instanceCopy, req.Name, req.Namespace); err != nil {
 		conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
@@ -109,9 +219,9 @@ func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			Reason:  "Unable to create UpdateService route",
 			Message: err.Error(),
 		})
-		if err := r.Client.Status().Update(ctx, instanceCopy); err != nil {
-			reqLogger.Error(err, "Failed to update Status")
-		}
+		r.recordConditionEvents(instanceCopy, instance.Status.Conditions)
+		observeStatus(instanceCopy, "")
+		r.updateStatus(ctx, reqLogger, instance, instanceCopy)
 		reqLogger.Error(err, "Unable to create UpdateService route")
 		return ctrl.Result{}, nil
 	}
@@ -133,21 +243,26 @@ func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
-	clusterCM, err := r.findTrustedClusterCAConfig(ctx, reqLogger, instanceCopy)
-	if apiErrors.IsNotFound(err) {
-		clusterCM = newTrustedClusterCAConfig(instanceCopy, clusterCM)
+	proxy, err := findProxy(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
 	// 2. Create all the kubeResources
 	//    'newKubeResources' creates all the kube resources we need and holds
 	//    them in 'resources' as the canonical reference for those resources
 	//    during reconciliation.
-	resources, err := newKubeResources(instanceCopy, r.OperandImage, ps, cm, clusterCM)
+	rendered := withOperatorDefaults(instanceCopy, config, r.OperandImage)
+	resources, err := newKubeResources(rendered, rendered.Spec.OperandImage, r.OperatorNamespace, proxy, ps, cm)
 	if err != nil {
 		reqLogger.Error(err, "Failed to render resources")
 		return ctrl.Result{}, err
 	}
 
+	if instanceCopy.Spec.ManagementState == cv1.ManagementStateUnmanaged {
+		return r.reportUnmanaged(ctx, reqLogger, instance, instanceCopy, resources)
+	}
+
 	conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
 		Type:    cv1.ConditionReconcileCompleted,
 		Status:  corev1.ConditionFalse,
@@ -157,35 +272,128 @@ func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// 3. Ensure all the kubeResources are correct in the Cluster
 	//    The ensure functions will compare the expected resources with the actual
-	//    resources and work towards making actual = expected.
-	for _, f := range []func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error{
-		r.ensureConfig,
-		r.ensurePullSecret,
-		r.ensureEnvConfig,
-		r.ensureTrustedClusterCA,
-		r.ensureAdditionalTrustedCA,
-		r.ensureGraphBuilderService,
-		r.ensurePolicyEngineService,
-		r.ensurePodDisruptionBudget,
-		r.ensurePolicyEngineRoute,
-		r.ensureNetworkPolicy,
-	} {
-		err = f(ctx, reqLogger, instanceCopy, resources)
+	//    resources and work towards making actual = expected. A failing resource
+	//    does not stop the others from being ensured; the failures are listed
+	//    in the status and returned together.
+	var errs []error
+	ensure := func(resource string, f func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error) error {
+		err := r.traced("ensure"+resource, f)(ctx, reqLogger, instanceCopy, resources)
 		if err != nil {
-			break
+			addResourceError(instanceCopy, resource, err)
+			errs = append(errs, fmt.Errorf("%s: %w", resource, err))
 		}
+		return err
 	}
 
-	imageSHA, err := r.ensureGraphDataSHA(ctx, reqLogger, instanceCopy)
-	if err != nil {
-		reqLogger.Error(err, "ensuring GraphData image checksum annotation")
+	// Resources reconciled by earlier operator versions are migrated before
+	// anything is applied to them.
+	migrated := ensure("Migrations", r.ensureMigrations) == nil
+
+	// The graph-data image to roll out is resolved first, as the Deployment
+	// depends on it. When signature verification is configured, only verified
+	// digests reach the Deployment. If there is no verified digest at all,
+	// hold the rollout.
+	shaCtx, shaSpan := r.startStep(ctx, "ensureGraphDataSHA")
+	imageSHA, shaErr := r.ensureGraphDataSHA(shaCtx, reqLogger, instanceCopy)
+	endSpan(shaSpan, shaErr)
+	if shaErr != nil {
+		reqLogger.Error(shaErr, "ensuring GraphData image checksum annotation")
 		// setting the imageSHA to an empty string to make sure we're not passing any garbage information as annotation
 		imageSHA = ""
 	}
 
-	err = r.ensureDeployment(ctx, reqLogger, instanceCopy, resources, imageSHA)
-	if err != nil {
-		return ctrl.Result{}, err
+	rolloutHeld := !migrated
+	if instanceCopy.Spec.GraphDataImageVerification != nil && !rolloutHeld {
+		err := ensure("GraphDataSignature", func(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+			var err error
+			imageSHA, err = r.ensureGraphDataSignature(ctx, reqLogger, instance, resources, imageSHA)
+			if errors.Is(err, errRolloutHeld) {
+				rolloutHeld = true
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			rolloutHeld = true
+		}
+	}
+	if !rolloutHeld {
+		err := ensure("GraphDataRollout", func(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+			var err error
+			imageSHA, err = r.ensureGraphDataRollout(ctx, reqLogger, instance, resources, imageSHA)
+			return err
+		})
+		if err != nil {
+			rolloutHeld = true
+		}
+	}
+
+	// The resources are only applied when their rendering changed since they
+	// were last applied, or when they were changed by others.
+	changes := r.renderings.changesOf(req.NamespacedName)
+	hash, hashErr := renderingHash(resources, imageSHA, rolloutHeld)
+	if hashErr != nil {
+		reqLogger.Error(hashErr, "Failed to hash the rendered resources")
+	}
+	applied := r.renderingApplied(instance, hash)
+	span.SetAttributes(attribute.Bool("updateservice.rendering_applied", applied))
+	if applied {
+		reqLogger.V(1).Info("The resources are up to date", "Hash", hash)
+		keepAppliedStatus(&instanceCopy.Status, instance.Status)
+	} else if migrated {
+		for _, step := range []struct {
+			resource string
+			f        func(context.Context, logr.Logger, *cv1.UpdateService, *kubeResources) error
+		}{
+			{"Config", r.ensureConfig},
+			{"PullSecret", r.ensurePullSecret},
+			{"EnvConfig", r.ensureEnvConfig},
+			{"TrustedClusterCA", r.ensureTrustedClusterCA},
+			{"AdditionalTrustedCA", r.ensureAdditionalTrustedCA},
+			{"GraphBuilderService", r.ensureGraphBuilderService},
+			{"PolicyEngineService", r.ensurePolicyEngineService},
+			{"PodDisruptionBudget", r.ensurePodDisruptionBudget},
+			{"PolicyEngineRoute", r.ensurePolicyEngineRoute},
+			{"NetworkPolicy", r.ensureNetworkPolicy},
+			{"ServiceMonitor", r.ensureServiceMonitor},
+			{"PrometheusRule", r.ensurePrometheusRule},
+			{"ConsoleDashboard", r.ensureConsoleDashboard},
+			{"ConsoleLink", r.ensureConsoleLink},
+		} {
+			ensure(step.resource, step.f)
+		}
+		if !rolloutHeld {
+			err := ensure("Deployment", func(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
+				return r.ensureDeployment(ctx, reqLogger, instance, resources, imageSHA)
+			})
+			if err != nil {
+				rolloutHeld = true
+			}
+		}
+		// Resources which are no longer rendered are only pruned once
+		// everything rendered was applied, so that nothing is replaced by
+		// a resource which failed.
+		if len(errs) == 0 {
+			ensure("Inventory", r.ensureInventory)
+			ensure("LegacyClusterTrustedCA", r.ensureLegacyClusterTrustedCA)
+		}
+	}
+	rolledOutDigest := ""
+	if !rolloutHeld {
+		rolledOutDigest = digestFromImageID(imageSHA)
+		if rolledOutDigest == "" {
+			// by-digest graph-data images are rolled out as they are
+			rolledOutDigest = digestFromImageID(instanceCopy.Spec.GraphDataImage)
+		}
+	}
+
+	ensure("GraphServing", r.ensureGraphServing)
+	ensure("GraphBuilderStatus", r.ensureGraphBuilderStatus)
+
+	instanceCopy.Status.ObservedGeneration = instance.Generation
+	if len(errs) == 0 && hash != "" {
+		instanceCopy.Status.LastAppliedHash = hash
+		r.renderings.record(req.NamespacedName, hash, changes)
 	}
 
 	// handle status. Ensure functions should set conditions on the passed-in
@@ -193,25 +401,62 @@ func (r *UpdateServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	// error, it should also set the ReconcileCompleted condition to false with an
 	// appropriate message. Otherwise it should set any other conditions as
 	// appropriate.
-	if err == nil {
+	err = utilerrors.NewAggregate(errs)
+	switch resourceErrors := instanceCopy.Status.ResourceErrors; len(resourceErrors) {
+	case 0:
 		conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
 			Type:    cv1.ConditionReconcileCompleted,
 			Status:  corev1.ConditionTrue,
 			Reason:  "Success",
 			Message: "",
 		})
+	case 1:
+		conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
+			Type:    cv1.ConditionReconcileCompleted,
+			Status:  corev1.ConditionFalse,
+			Reason:  resourceErrors[0].Reason,
+			Message: resourceErrors[0].Message,
+		})
+	default:
+		conditionsv1.SetStatusCondition(&instanceCopy.Status.Conditions, conditionsv1.Condition{
+			Type:    cv1.ConditionReconcileCompleted,
+			Status:  corev1.ConditionFalse,
+			Reason:  "MultipleResourcesFailed",
+			Message: err.Error(),
+		})
 	}
 
-	if err := r.Client.Status().Update(ctx, instanceCopy); err != nil {
-		reqLogger.Error(err, "Failed to update Status")
+	r.recordConditionEvents(instanceCopy, instance.Status.Conditions)
+	observeStatus(instanceCopy, rolledOutDigest)
+	r.updateStatus(ctx, reqLogger, instance, instanceCopy)
+
+	if err != nil {
+		// let controller-runtime back off while resources keep failing
+		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: r.rolloutRequeueAfter(instanceCopy, r.resyncPeriod(ctx))}, nil
+}
 
-	return ctrl.Result{RequeueAfter: time.Duration(5 * time.Minute)}, err
+// addResourceError lists err in the status as the failure of resource. The
+// reason is the one the ensure function set on the ReconcileCompleted
+// condition.
+func addResourceError(instance *cv1.UpdateService, resource string, err error) {
+	reason := "ReconcileFailed"
+	if c := conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionReconcileCompleted); c != nil && c.Status == corev1.ConditionFalse && c.Message == err.Error() {
+		reason = c.Reason
+	}
+	instance.Status.ResourceErrors = append(instance.Status.ResourceErrors, cv1.ResourceError{
+		Resource: resource,
+		Reason:   reason,
+		Message:  err.Error(),
+	})
 }
 
-// handleErr logs the error and sets an appropriate Condition on the status.
-func handleErr(reqLogger logr.Logger, status *cv1.UpdateServiceStatus, reason string, e error) {
-	conditionsv1.SetStatusCondition(&status.Conditions, conditionsv1.Condition{
+// handleErr logs the error, records a Warning event and sets an appropriate
+// Condition on the status.
+func (r *UpdateServiceReconciler) handleErr(reqLogger logr.Logger, instance *cv1.UpdateService, reason string, e error) {
+	r.event(instance, corev1.EventTypeWarning, reason, e.Error())
+	conditionsv1.SetStatusCondition(&instance.Status.Conditions, conditionsv1.Condition{
 		Type:    cv1.ConditionReconcileCompleted,
 		Status:  corev1.ConditionFalse,
 		Reason:  reason,
@@ -231,12 +476,20 @@ func handleCACertStatus(reqLogger logr.Logger, status *cv1.UpdateServiceStatus,
 	reqLogger.Info(message)
 }
 
+// apiReader returns the reader for objects which the manager does not cache.
+func (r *UpdateServiceReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
 // findPullSecet - Locate the PullSecrt in openshift-config and return it
 func (r *UpdateServiceReconciler) findPullSecret(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService) (*corev1.Secret, error) {
 	sourcePS := &corev1.Secret{}
 	err := r.Client.Get(ctx, types.NamespacedName{Name: namePullSecret, Namespace: OpenshiftConfigNamespace}, sourcePS)
 	if err != nil && apiErrors.IsNotFound(err) {
-		handleErr(reqLogger, &instance.Status, "PullSecretNotFound", err)
+		r.handleErr(reqLogger, instance, "PullSecretNotFound", err)
 		return nil, err
 	} else if err != nil {
 		return nil, err
@@ -267,7 +520,7 @@ func (r *UpdateServiceReconciler) findTrustedCAConfig(ctx context.Context, reqLo
 
 	// Search for the ConfigMap in openshift-config
 	sourceCM := &corev1.ConfigMap{}
-	err = r.Client.Get(ctx, types.NamespacedName{Name: image.Spec.AdditionalTrustedCA.Name, Namespace: OpenshiftConfigNamespace}, sourceCM)
+	err = r.apiReader().Get(ctx, types.NamespacedName{Name: image.Spec.AdditionalTrustedCA.Name, Namespace: OpenshiftConfigNamespace}, sourceCM)
 	if err != nil && apiErrors.IsNotFound(err) {
 		m := fmt.Sprintf("Found image.config.openshift.io.Spec.AdditionalTrustedCA.Name but did not find expected ConfigMap (Name: %v, Namespace: %v)", image.Spec.AdditionalTrustedCA.Name, OpenshiftConfigNamespace)
 		handleCACertStatus(reqLogger, &instance.Status, "FindAdditionalTrustedCAFailed", m)
@@ -286,24 +539,6 @@ func (r *UpdateServiceReconciler) findTrustedCAConfig(ctx context.Context, reqLo
 }
 
 // findTrustedCAConfig - Locate the ConfigMap referenced by the ImageConfig resource in openshift-config and return it
-func (r *UpdateServiceReconciler) findTrustedClusterCAConfig(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService) (*corev1.ConfigMap, error) {
-
-	// Search for the ConfigMap in the operator namespace
-	sourceCM := &corev1.ConfigMap{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: NameClusterTrustedCAVolume, Namespace: instance.Namespace}, sourceCM)
-	if err != nil {
-		return nil, err
-	}
-
-	if _, ok := sourceCM.Data[NameClusterCertConfigMapKey]; !ok {
-		m := fmt.Sprintf("Found cluster-wide CA but required key: '%v' not found", NameClusterCertConfigMapKey)
-		handleCACertStatus(reqLogger, &instance.Status, "EnsureTrustedClusterCAFailed", m)
-		return nil, nil
-	}
-
-	return sourceCM, nil
-}
-
 func (r *UpdateServiceReconciler) ensurePullSecret(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
 	if resources.pullSecret == nil {
 		return nil
@@ -314,8 +549,8 @@ func (r *UpdateServiceReconciler) ensurePullSecret(ctx context.Context, reqLogge
 		return err
 	}
 
-	if err := r.ensureSecret(ctx, reqLogger, resources.pullSecret); err != nil {
-		handleErr(reqLogger, &instance.Status, "EnsureSecretFailed", err)
+	if err := r.ensureSecret(ctx, reqLogger, instance, resources.pullSecret); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureSecretFailed", err)
 		return err
 	}
 
@@ -341,33 +576,35 @@ func (r *UpdateServiceReconciler) ensureAdditionalTrustedCA(ctx context.Context,
 		return err
 	}
 
-	if err := r.ensureConfigMap(ctx, reqLogger, resources.trustedCAConfig); err != nil {
-		handleErr(reqLogger, &instance.Status, "EnsureConfigMapFailed", err)
+	if err := r.ensureConfigMap(ctx, reqLogger, instance, resources.trustedCAConfig); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureConfigMapFailed", err)
 		return err
 	}
 	return nil
 }
 
 func (r *UpdateServiceReconciler) ensureTrustedClusterCA(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
-
-	if resources.trustedClusterCAConfig == nil {
+	config := resources.trustedClusterCAConfig
+	if config == nil {
 		return nil
 	}
 
-	_, err := r.findTrustedClusterCAConfig(ctx, reqLogger, instance)
-	if apiErrors.IsNotFound(err) {
-		// Set UpdateService instance as the owner and controller
-		if err := controllerutil.SetControllerReference(instance, resources.trustedClusterCAConfig, r.Scheme); err != nil {
-			return err
-		}
+	// Set UpdateService instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, config, r.Scheme); err != nil {
+		return err
+	}
 
-		if err := r.ensureConfigMap(ctx, reqLogger, resources.trustedClusterCAConfig); err != nil {
-			handleErr(reqLogger, &instance.Status, "EnsureConfigMapFailedForClusterCA", err)
-			return err
-		}
-		return nil
+	// the operator applies the ConfigMap without data, and the cluster
+	// network operator injects the CA bundle into it
+	if err := r.ensureConfigMap(ctx, reqLogger, instance, config); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureConfigMapFailedForClusterCA", err)
+		return err
+	}
+	if _, ok := config.Data[NameClusterCertConfigMapKey]; !ok {
+		m := fmt.Sprintf("The cluster-wide CA key: '%v' was not injected into ConfigMap %s yet", NameClusterCertConfigMapKey, config.Name)
+		handleCACertStatus(reqLogger, &instance.Status, "EnsureTrustedClusterCAFailed", m)
 	}
-	return err
+	return nil
 }
 
 func (r *UpdateServiceReconciler) ensureGraphDataSHA(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService) (string, error) {
@@ -380,6 +617,9 @@ func (r *UpdateServiceReconciler) ensureGraphDataSHA(ctx context.Context, reqLog
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "graph-data-tag-digest",
 			Namespace: instance.Namespace,
+			Labels: map[string]string{
+				GraphDataPodLabel: "true",
+			},
 			Annotations: map[string]string{
 				"kubernetes.io/description":                        "Resolve any by-tag graph-data images into by-digest pullspecs, so we can update update service Deployments if the by-tag graph data image their UpdateService requests has updated content.  The current implementation polls every 5 minutes, and the container does nothing other than request a fresh image pull.  If you want to avoid this polling, use by-digest pullspecs in all UpdateServices, and update the digest in those UpdateServices when the content of the graph-data image changes.",
 				"updateservice.operator.openshift.io/last-refresh": time.Now().UTC().Format(time.RFC822),
@@ -408,19 +648,28 @@ func (r *UpdateServiceReconciler) ensureGraphDataSHA(ctx context.Context, reqLog
 	err := r.Client.Get(ctx, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, found)
 
 	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating Pod", "Namespace", pod.Namespace, "Name", pod.Name)
-		err := r.Client.Create(ctx, pod)
+		reqLogger.Info("Creating Pod", "Kind", "Pod", "Namespace", pod.Namespace, "Name", pod.Name)
+		err := r.create(ctx, instance, pod)
+		if apiErrors.IsAlreadyExists(err) {
+			// a pod created before GraphDataPodLabel is not cached; replace it
+			reqLogger.Info("Deleting unlabeled Pod", "Kind", "Pod", "Namespace", pod.Namespace, "Name", pod.Name)
+			if err := r.delete(ctx, instance, pod); err != nil && !apiErrors.IsNotFound(err) {
+				r.handleErr(reqLogger, instance, "DeleteGraphDataPodFailed", err)
+				return "", err
+			}
+			return "", nil
+		}
 		if err != nil {
-			handleErr(reqLogger, &instance.Status, "CreateGraphDataPodFailed", err)
+			r.handleErr(reqLogger, instance, "CreateGraphDataPodFailed", err)
 		}
 		return "", err
 	} else if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetGraphDataPodFailed", err)
+		r.handleErr(reqLogger, instance, "GetGraphDataPodFailed", err)
 		return "", err
 	} else if found.Status.Phase == corev1.PodSucceeded {
-		err := r.Client.Delete(ctx, pod)
+		err := r.delete(ctx, instance, pod)
 		if err != nil {
-			handleErr(reqLogger, &instance.Status, "DeleteGraphDataPodFailed", err)
+			r.handleErr(reqLogger, instance, "DeleteGraphDataPodFailed", err)
 		}
 		return "", err
 	}
@@ -428,7 +677,7 @@ func (r *UpdateServiceReconciler) ensureGraphDataSHA(ctx context.Context, reqLog
 	if len(found.Status.ContainerStatuses) > 0 {
 		return found.Status.ContainerStatuses[0].ImageID, nil
 	} else {
-		handleErr(reqLogger, &instance.Status, "GraphDataPodStatusEmpty", fmt.Errorf("Graph-Data pod returned empty container status"))
+		r.handleErr(reqLogger, instance, "GraphDataPodStatusEmpty", fmt.Errorf("Graph-Data pod returned empty container status"))
 	}
 
 	return "", nil
@@ -441,130 +690,44 @@ func (r *UpdateServiceReconciler) ensureDeployment(ctx context.Context, reqLogge
 	if err := controllerutil.SetControllerReference(instance, deployment, r.Scheme); err != nil {
 		return err
 	}
-	// Check if this deployment already exists
-	found := &appsv1.Deployment{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, found)
-
-	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating Deployment", "Namespace", deployment.Namespace, "Name", deployment.Name)
-		err := r.Client.Create(ctx, deployment)
-		if err != nil {
-			handleErr(reqLogger, &instance.Status, "CreateDeploymentFailed", err)
-		}
-		return err
-	} else if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetDeploymentFailed", err)
-		return err
-	}
-
-	// found existing deployment; let's compare and update if needed. We'll make
-	// a copy of what we found, overwrite values with those that were just now
-	// generated from the spec, and compare the result with what was found.
-	updated := found.DeepCopy()
-	updated.Spec.Replicas = deployment.Spec.Replicas
-	updated.Spec.Selector = deployment.Spec.Selector
-	updated.Spec.Strategy = deployment.Spec.Strategy
-
-	// apply labels and annotations
-	if updated.Spec.Template.ObjectMeta.Labels == nil {
-		updated.Spec.Template.ObjectMeta.Labels = map[string]string{}
-	}
-	for key, value := range deployment.Spec.Template.ObjectMeta.Labels {
-		updated.Spec.Template.ObjectMeta.Labels[key] = value
-	}
-	if updated.Spec.Template.ObjectMeta.Annotations == nil {
-		updated.Spec.Template.ObjectMeta.Annotations = map[string]string{}
-	}
-	for key, value := range deployment.Spec.Template.ObjectMeta.Annotations {
-		updated.Spec.Template.ObjectMeta.Annotations[key] = value
-	}
-
 	if len(imageSHA) > 0 {
-		reqLogger.Info("Setting SHA annotation")
-		updated.Spec.Template.ObjectMeta.Annotations["updateservice.operator.openshift.io/graph-data-image"] = imageSHA
-	}
-
-	updated.Spec.Template.Spec.Volumes = deployment.Spec.Template.Spec.Volumes
-	containers := updated.Spec.Template.Spec.Containers
-	for i := range containers {
-		var original *corev1.Container
-		switch containers[i].Name {
-		case NameContainerGraphBuilder:
-			original = resources.graphBuilderContainer
-		case NameContainerPolicyEngine:
-			original = resources.policyEngineContainer
-		default:
-			reqLogger.Info("encountered unexpected container in pod", "Container.Name", containers[i].Name)
-			continue
-		}
-		containers[i].Image = original.Image
-		containers[i].ImagePullPolicy = original.ImagePullPolicy
-		containers[i].Command = original.Command
-		containers[i].Args = original.Args
-		containers[i].Ports = original.Ports
-		containers[i].Env = original.Env
-
-		// Resources don't seem to work with DeepEqual, so we check equality
-		// manually.
-		if containers[i].Resources.Limits == nil {
-			containers[i].Resources.Limits = corev1.ResourceList{}
-		}
-		for k, origVal := range original.Resources.Limits {
-			value, ok := containers[i].Resources.Limits[k]
-			if !ok || !origVal.Equal(value) {
-				containers[i].Resources.Limits[k] = origVal
-			}
-		}
-		if containers[i].Resources.Requests == nil {
-			containers[i].Resources.Requests = corev1.ResourceList{}
-		}
-		for k, origVal := range original.Resources.Requests {
-			value, ok := containers[i].Resources.Requests[k]
-			if !ok || !origVal.Equal(value) {
-				containers[i].Resources.Requests[k] = origVal
-			}
-		}
-		containers[i].VolumeMounts = original.VolumeMounts
-		containers[i].LivenessProbe = original.LivenessProbe
-		containers[i].ReadinessProbe = original.ReadinessProbe
-	}
-
-	graphDataInitContainerIdx := -1
-	removeUnexpected := false
-	initContainers := updated.Spec.Template.Spec.InitContainers
-	for i := range initContainers {
-		var original *corev1.Container
-		if initContainers[i].Name == "graph-data" {
-			graphDataInitContainerIdx = i
-			original = resources.graphDataInitContainer
-		} else {
-			reqLogger.Info("Unexpected init container in pod will be removed", "Container.Name", initContainers[i].Name)
-			removeUnexpected = true
-			continue
+		if deployment.Spec.Template.ObjectMeta.Annotations == nil {
+			deployment.Spec.Template.ObjectMeta.Annotations = map[string]string{}
 		}
-		initContainers[i].Image = original.Image
-		initContainers[i].ImagePullPolicy = original.ImagePullPolicy
-		initContainers[i].VolumeMounts = original.VolumeMounts
+		deployment.Spec.Template.ObjectMeta.Annotations[GraphDataImageAnnotation] = imageSHA
 	}
 
-	if graphDataInitContainerIdx == -1 {
-		handleErr(reqLogger, &instance.Status, "UpdateDeploymentFailed", errors.New("Graph data init container not found"))
+	found, err := r.findApplied(ctx, deployment)
+	if err != nil {
+		r.handleErr(reqLogger, instance, "GetDeploymentFailed", err)
 		return err
 	}
-
-	if removeUnexpected {
-		initContainers = append([]corev1.Container(nil), initContainers[graphDataInitContainerIdx])
+	if found != nil && managedByOthers(found, "f:spec", "f:replicas") {
+		// another manager, such as a HorizontalPodAutoscaler, scales the
+		// Deployment, so its replicas are not applied
+		deployment = deployment.DeepCopy()
+		deployment.Spec.Replicas = nil
 	}
 
-	if !reflect.DeepEqual(updated.Spec, found.Spec) {
-		reqLogger.Info("Updating Deployment", "Namespace", deployment.Namespace, "Name", deployment.Name)
-		err = r.Client.Update(ctx, updated)
-		if err != nil {
-			handleErr(reqLogger, &instance.Status, "UpdateDeploymentFailed", err)
+	if instance.Spec.RolloutSchedule != nil && found != nil {
+		// The pending changes are found by comparing with a dry-run of the
+		// apply, which unlike the applied fields has server defaults.
+		result := deployment.DeepCopy()
+		if err := r.serverSideApply(ctx, result, client.DryRunAll); err != nil {
+			r.handleErr(reqLogger, instance, "ApplyDeploymentFailed", err)
 			return err
 		}
+		foundDeployment := found.(*appsv1.Deployment)
+		if r.holdRollout(reqLogger, instance, foundDeployment, result) {
+			deployment = deployment.DeepCopy()
+			deployment.Spec.Template = *foundDeployment.Spec.Template.DeepCopy()
+		}
 	}
 
+	if err := r.apply(ctx, reqLogger, instance, deployment); err != nil {
+		r.handleErr(reqLogger, instance, "ApplyDeploymentFailed", err)
+		return err
+	}
 	return nil
 }
 
@@ -575,31 +738,10 @@ func (r *UpdateServiceReconciler) ensurePodDisruptionBudget(ctx context.Context,
 		return err
 	}
 
-	// Check if it already exists
-	found := &policyv1.PodDisruptionBudget{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: pdb.Name, Namespace: pdb.Namespace}, found)
-	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating PodDisruptionBudget", "Namespace", pdb.Namespace, "Name", pdb.Name)
-		if err = r.Client.Create(ctx, pdb); err != nil {
-			handleErr(reqLogger, &instance.Status, "CreatePDBFailed", err)
-		}
-		return err
-	} else if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetPDBFailed", err)
+	if err := r.apply(ctx, reqLogger, instance, pdb); err != nil {
+		r.handleErr(reqLogger, instance, "ApplyPDBFailed", err)
 		return err
 	}
-
-	// found existing resource; let's compare and update if needed
-	if !reflect.DeepEqual(found.Spec, pdb.Spec) {
-		reqLogger.Info("Updating PodDisruptionBudget", "Namespace", pdb.Namespace, "Name", pdb.Name)
-		updated := found.DeepCopy()
-		updated.Spec = pdb.Spec
-		err = r.Client.Update(ctx, updated)
-		if err != nil {
-			handleErr(reqLogger, &instance.Status, "UpdatePDBFailed", err)
-		}
-	}
-
 	return nil
 }
 
@@ -610,8 +752,18 @@ func (r *UpdateServiceReconciler) ensureConfig(ctx context.Context, reqLogger lo
 		return err
 	}
 
-	if err := r.ensureConfigMap(ctx, reqLogger, config); err != nil {
-		handleErr(reqLogger, &instance.Status, "EnsureConfigMapFailed", err)
+	held, err := r.holdConfig(ctx, instance, resources, config, GraphBuilderConfigHashAnnotation)
+	if err != nil {
+		r.handleErr(reqLogger, instance, "GetDeploymentFailed", err)
+		return err
+	}
+	if held {
+		reqLogger.Info("Holding ConfigMap update until the next maintenance window", "Kind", "ConfigMap", "Name", config.Name)
+		return nil
+	}
+
+	if err := r.ensureConfigMap(ctx, reqLogger, instance, config); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureConfigMapFailed", err)
 		return err
 	}
 	return nil
@@ -624,8 +776,18 @@ func (r *UpdateServiceReconciler) ensureEnvConfig(ctx context.Context, reqLogger
 		return err
 	}
 
-	if err := r.ensureConfigMap(ctx, reqLogger, config); err != nil {
-		handleErr(reqLogger, &instance.Status, "EnsureConfigMapFailed", err)
+	held, err := r.holdConfig(ctx, instance, resources, config, EnvConfigHashAnnotation)
+	if err != nil {
+		r.handleErr(reqLogger, instance, "GetDeploymentFailed", err)
+		return err
+	}
+	if held {
+		reqLogger.Info("Holding ConfigMap update until the next maintenance window", "Kind", "ConfigMap", "Name", config.Name)
+		return nil
+	}
+
+	if err := r.ensureConfigMap(ctx, reqLogger, instance, config); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureConfigMapFailed", err)
 		return err
 	}
 	return nil
@@ -638,8 +800,8 @@ func (r *UpdateServiceReconciler) ensureGraphBuilderService(ctx context.Context,
 		return err
 	}
 
-	if err := r.ensureService(ctx, reqLogger, service); err != nil {
-		handleErr(reqLogger, &instance.Status, "EnsureServiceFailed", err)
+	if err := r.ensureService(ctx, reqLogger, instance, service); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureServiceFailed", err)
 		return err
 	}
 	return nil
@@ -652,8 +814,8 @@ func (r *UpdateServiceReconciler) ensurePolicyEngineService(ctx context.Context,
 		return err
 	}
 
-	if err := r.ensureService(ctx, reqLogger, service); err != nil {
-		handleErr(reqLogger, &instance.Status, "EnsureServiceFailed", err)
+	if err := r.ensureService(ctx, reqLogger, instance, service); err != nil {
+		r.handleErr(reqLogger, instance, "EnsureServiceFailed", err)
 		return err
 	}
 	return nil
@@ -683,147 +845,64 @@ func validateRouteName(instance *cv1.UpdateService, name string, namespace strin
 
 func (r *UpdateServiceReconciler) ensurePolicyEngineRoute(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
 	route := resources.policyEngineRoute
-	foundRoute, err := r.findExistingRoute(ctx, reqLogger, instance, resources)
-	if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetRouteFailed", err)
+	if route == nil {
+		// exposed inside of the cluster only; a Route left by the Route
+		// exposure is pruned with the inventory
+		instance.Status.PolicyEngineURI = (&url.URL{
+			Scheme: "http",
+			Host:   fmt.Sprintf("%s.%s.svc", resources.policyEngineService.Name, resources.policyEngineService.Namespace),
+		}).String()
+		return nil
+	}
+	// Set UpdateService instance as the owner and controller
+	if err := controllerutil.SetControllerReference(instance, route, r.Scheme); err != nil {
 		return err
-	} else if foundRoute == nil {
+	}
 
-		// Set UpdateService instance as the owner and controller
-		if err = controllerutil.SetControllerReference(instance, route, r.Scheme); err != nil {
-			return err
-		}
-		reqLogger.Info("Creating Route", "Namespace", route.Namespace, "Name", route.Name)
-		if err = r.Client.Create(ctx, route); err != nil {
-			handleErr(reqLogger, &instance.Status, "CreateRouteFailed", err)
+	foundRoute := &routev1.Route{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: route.Name, Namespace: route.Namespace}, foundRoute)
+	if err != nil && apiErrors.IsNotFound(err) {
+		// The TLS configuration is only set when the Route is created,
+		// rather than applied, so that it can be changed by hand.
+		reqLogger.Info("Creating Route", "Kind", "Route", "Namespace", route.Namespace, "Name", route.Name)
+		if err = r.create(ctx, instance, route); err != nil {
+			r.handleErr(reqLogger, instance, "CreateRouteFailed", err)
 		}
 		return err
+	} else if err != nil {
+		r.handleErr(reqLogger, instance, "GetRouteFailed", err)
+		return err
 	}
 
 	if uri, _, err := routeapihelpers.IngressURI(foundRoute, ""); err == nil {
 		instance.Status.PolicyEngineURI = uri.String()
 	} else {
-		handleErr(reqLogger, &instance.Status, "RouteIngressFailed", err)
-	}
-
-	updated := foundRoute.DeepCopy()
-	// Keep found tls for later use
-	tls := updated.Spec.TLS
-	// This is just so we compare the Spec on the two objects but make an exception for Spec.TLS
-	updated.Spec.TLS = route.Spec.TLS
-
-	// found existing resource; let's compare and update if needed
-	if !reflect.DeepEqual(updated.Spec, route.Spec) {
-		reqLogger.Info("Updating Route", "Namespace", route.Namespace, "Name", route.Name)
-		updated.Spec = route.Spec
-		// We want to allow user to update the TLS cert/key manually on the route and we don't want to override that change.
-		// Keep the existing tls on the route
-		updated.Spec.TLS = tls
-		err = r.Client.Update(ctx, updated)
-		if err != nil {
-			handleErr(reqLogger, &instance.Status, "UpdateRouteFailed", err)
-		}
-	}
-
-	return nil
-}
-
-// Returns the route object if there are existing route and returns error when get route fails.
-func (r *UpdateServiceReconciler) findExistingRoute(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) (*routev1.Route, error) {
-	oldRoute := resources.policyEngineOldRoute
-	route := resources.policyEngineRoute
-
-	foundRoute := &routev1.Route{}
-	foundOldRoute := &routev1.Route{}
-	oldRoutePresent := true
-
-	err := r.Client.Get(ctx, types.NamespacedName{Name: oldRoute.Name, Namespace: oldRoute.Namespace}, foundOldRoute)
-	if err != nil && apiErrors.IsNotFound(err) {
-		oldRoutePresent = false
-	} else if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetRouteFailed", err)
-		return nil, err
-	}
-	if oldRoutePresent {
-		return foundOldRoute, nil
+		r.handleErr(reqLogger, instance, "RouteIngressFailed", err)
 	}
-	err = r.Client.Get(ctx, types.NamespacedName{Name: route.Name, Namespace: route.Namespace}, foundRoute)
-	if err != nil && apiErrors.IsNotFound(err) {
-		return nil, nil
-	} else if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetRouteFailed", err)
-		return nil, err
-	}
-	return foundRoute, nil
-}
 
-func (r *UpdateServiceReconciler) ensureService(ctx context.Context, reqLogger logr.Logger, service *corev1.Service) error {
-	// Check if this Service already exists
-	found := &corev1.Service{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, found)
-	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating Service", "Namespace", service.Namespace, "Name", service.Name)
-		return r.Client.Create(ctx, service)
-	} else if err != nil {
+	// The host is generated by the router unless set, and migrated from the
+	// legacy Route, and the TLS certificate and key may be updated by hand,
+	// so the operator leaves both unowned.
+	applied := route.DeepCopy()
+	applied.Spec.Host = ""
+	applied.Spec.TLS = nil
+	if err := r.apply(ctx, reqLogger, instance, applied); err != nil {
+		r.handleErr(reqLogger, instance, "ApplyRouteFailed", err)
 		return err
 	}
-
-	// found existing configmap; let's compare and update if needed
-	// ClusterIP gets set externally, so we need to set it before comparing
-	service.Spec.ClusterIP = found.Spec.ClusterIP
-	if !reflect.DeepEqual(found.Spec, service.Spec) {
-		reqLogger.Info("Updating Service", "Namespace", service.Namespace, "Name", service.Name)
-		updated := found.DeepCopy()
-		updated.Spec = service.Spec
-		return r.Client.Update(ctx, updated)
-	}
-
 	return nil
 }
 
-func (r *UpdateServiceReconciler) ensureConfigMap(ctx context.Context, reqLogger logr.Logger, cm *corev1.ConfigMap) error {
-
-	// Check if this configmap already exists
-	found := &corev1.ConfigMap{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}, found)
-	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating ConfigMap", "Namespace", cm.Namespace, "Name", cm.Name)
-		return r.Client.Create(ctx, cm)
-	} else if err != nil {
-		return err
-	}
-
-	// found existing configmap; let's compare and update if needed
-	if !reflect.DeepEqual(found.Data, cm.Data) {
-		reqLogger.Info("Updating ConfigMap", "Namespace", cm.Namespace, "Name", cm.Name)
-		updated := found.DeepCopy()
-		updated.Data = cm.Data
-		return r.Client.Update(ctx, updated)
-	}
-
-	return nil
+func (r *UpdateServiceReconciler) ensureService(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, service *corev1.Service) error {
+	return r.apply(ctx, reqLogger, instance, service)
 }
 
-func (r *UpdateServiceReconciler) ensureSecret(ctx context.Context, reqLogger logr.Logger, secret *corev1.Secret) error {
-	// Check if this secret already exists
-	found := &corev1.Secret{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, found)
-	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating Secret", "Namespace", secret.Namespace, "Name", secret.Name)
-		return r.Client.Create(ctx, secret)
-	} else if err != nil {
-		return err
-	}
-
-	// found existing secret; let's compare and update if needed
-	if !reflect.DeepEqual(found.Data, secret.Data) {
-		reqLogger.Info("Updating Secret", "Namespace", secret.Namespace, "Name", secret.Name)
-		updated := found.DeepCopy()
-		updated.Data = secret.Data
-		return r.Client.Update(ctx, updated)
-	}
+func (r *UpdateServiceReconciler) ensureConfigMap(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, cm *corev1.ConfigMap) error {
+	return r.apply(ctx, reqLogger, instance, cm)
+}
 
-	return nil
+func (r *UpdateServiceReconciler) ensureSecret(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, secret *corev1.Secret) error {
+	return r.apply(ctx, reqLogger, instance, secret)
 }
 
 func (r *UpdateServiceReconciler) ensureNetworkPolicy(ctx context.Context, reqLogger logr.Logger, instance *cv1.UpdateService, resources *kubeResources) error {
@@ -833,54 +912,95 @@ func (r *UpdateServiceReconciler) ensureNetworkPolicy(ctx context.Context, reqLo
 		return err
 	}
 
-	// Check if it already exists
-	found := &networkingv1.NetworkPolicy{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace}, found)
-	if err != nil && apiErrors.IsNotFound(err) {
-		reqLogger.Info("Creating NetworkPolicy", "Namespace", policy.Namespace, "Name", policy.Name)
-		if err = r.Client.Create(ctx, policy); err != nil {
-			handleErr(reqLogger, &instance.Status, "CreateNetworkPolicyFailed", err)
-		}
-		return err
-	} else if err != nil {
-		handleErr(reqLogger, &instance.Status, "GetNetworkPolicyFailed", err)
+	if err := r.apply(ctx, reqLogger, instance, policy); err != nil {
+		r.handleErr(reqLogger, instance, "ApplyNetworkPolicyFailed", err)
 		return err
 	}
-
-	// found existing resource; let's compare and update if needed
-	if !reflect.DeepEqual(found.Spec, policy.Spec) {
-		reqLogger.Info("Updating NetworkPolicy", "Namespace", policy.Namespace, "Name", policy.Name)
-		updated := found.DeepCopy()
-		updated.Spec = policy.Spec
-		err = r.Client.Update(ctx, updated)
-		if err != nil {
-			handleErr(reqLogger, &instance.Status, "UpdateNetworkPolicyFailed", err)
-		}
-	}
-
 	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *UpdateServiceReconciler) SetupWithManager(mgr ctrl.Manager, namespace string) error {
-	mapped := &mapper{client: mgr.GetClient(), namespace: namespace}
-
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&cv1.UpdateService{}).
-		Owns(&appsv1.Deployment{}).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.Service{}).
-		Owns(&policyv1.PodDisruptionBudget{}).
-		Owns(&routev1.Route{}).
-		Owns(&networkingv1.NetworkPolicy{}).
-		Owns(&corev1.Pod{}).
+func (r *UpdateServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	mapped := &mapper{client: mgr.GetClient(), namespaces: r.watchedNamespaces()}
+	if r.Recorder == nil {
+		r.Recorder = newDedupingRecorder(mgr.GetEventRecorderFor(operatorName), eventRepeatInterval)
+	}
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+
+	// The ConfigMaps in openshift-config are only watched for their metadata,
+	// in a cache of their own, so that the manager does not cache the data of
+	// all of them for the one holding the registry CA.
+	openshiftConfig, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient:        mgr.GetHTTPClient(),
+		Scheme:            mgr.GetScheme(),
+		Mapper:            mgr.GetRESTMapper(),
+		DefaultNamespaces: map[string]cache.Config{OpenshiftConfigNamespace: {}},
+	})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(openshiftConfig); err != nil {
+		return err
+	}
+	configMapMetadata := &metav1.PartialObjectMetadata{}
+	configMapMetadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+	// changes to the resources are counted, so that they are applied again
+	changes := r.renderings.watchChanges()
+	ignoreStatus := builder.WithPredicates(specChanged)
+	owned := builder.WithPredicates(specChanged, changes)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&cv1.UpdateService{}, ignoreStatus).
+		Owns(&appsv1.Deployment{}, builder.WithPredicates(predicate.Or(specChanged, statusChanged(deploymentRollout)), changes)).
+		Owns(&corev1.ConfigMap{}, owned).
+		Owns(&corev1.Service{}, owned).
+		Owns(&policyv1.PodDisruptionBudget{}, owned).
+		Owns(&routev1.Route{}, builder.WithPredicates(predicate.Or(specChanged, statusChanged(routeIngress)), changes)).
+		Owns(&networkingv1.NetworkPolicy{}, owned).
+		Owns(&corev1.Pod{}, builder.WithPredicates(statusChanged(graphDataPodResolution))).
+		Owns(&rbacv1.Role{}, owned).
+		Owns(&rbacv1.RoleBinding{}, owned).
 		Watches(
 			&apicfgv1.Image{},
 			handler.EnqueueRequestsFromMapFunc(mapped.Map),
+			ignoreStatus,
+		).
+		// the cluster-wide proxy settings are read from the status of the
+		// Proxy
+		Watches(
+			&apicfgv1.Proxy{},
+			handler.EnqueueRequestsFromMapFunc(mapped.Map),
 		).
 		Watches(
-			&corev1.ConfigMap{},
+			&cv1.UpdateServiceOperatorConfig{},
 			handler.EnqueueRequestsFromMapFunc(mapped.Map),
+			ignoreStatus,
 		).
-		Complete(r)
+		// the limiter bounds the reconciles by the configured
+		// maxConcurrentReconciles
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconcilesLimit}).
+		WatchesRawSource(source.Kind[client.Object](
+			openshiftConfig,
+			configMapMetadata,
+			handler.EnqueueRequestsFromMapFunc(mapped.Map),
+			predicate.ResourceVersionChangedPredicate{},
+		))
+
+	// ServiceMonitors and PrometheusRules can only be watched when the
+	// monitoring CRDs exist.
+	for kind, obj := range map[string]client.Object{
+		monitoringv1.ServiceMonitorsKind: &monitoringv1.ServiceMonitor{},
+		monitoringv1.PrometheusRuleKind:  &monitoringv1.PrometheusRule{},
+	} {
+		monitoring, err := monitoringAvailable(mgr.GetRESTMapper(), kind)
+		if err != nil {
+			return err
+		}
+		if monitoring {
+			b = b.Owns(obj, owned)
+		}
+	}
+	return b.Complete(r)
 }
@@ -8,10 +8,12 @@ import (
 	"testing"
 
 	configv1 "github.com/openshift/api/config/v1"
+	consolev1 "github.com/openshift/api/console/v1"
 	routev1 "github.com/openshift/api/route/v1"
 	cv1 "github.com/openshift/cincinnati-operator/api/v1"
 	"github.com/openshift/cluster-image-registry-operator/pkg/defaults"
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/stretchr/testify/assert"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -24,7 +26,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
-	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -57,6 +60,16 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	if err := monitoringv1.AddToScheme(scheme.Scheme); err != nil {
+		log.Error(err, "Failed adding apis to scheme")
+		os.Exit(1)
+	}
+
+	if err := consolev1.AddToScheme(scheme.Scheme); err != nil {
+		log.Error(err, "Failed adding apis to scheme")
+		os.Exit(1)
+	}
+
 	exitVal := m.Run()
 	os.Exit(exitVal)
 }
@@ -82,6 +95,42 @@ func TestReconcile(t *testing.T) {
 					Type:   cv1.ConditionRegistryCACertFound,
 					Status: corev1.ConditionFalse,
 				},
+				{
+					Type:   cv1.ConditionMigrationsCompleted,
+					Status: corev1.ConditionTrue,
+				},
+			},
+		},
+		{
+			name: "ReconcileHoldsUnverifiedGraphData",
+			existingObjs: []runtime.Object{
+				func() *cv1.UpdateService {
+					updateservice := newDefaultUpdateService()
+					updateservice.Spec.GraphDataImageVerification = &cv1.GraphDataImageVerification{
+						Type:      cv1.SignatureTypeCosign,
+						PublicKey: cv1.ConfigMapKeyReference{Name: "missing", Key: "key.pub"},
+					}
+					return updateservice
+				}(),
+				newSecret(),
+			},
+			expectedConditions: []conditionsv1.Condition{
+				{
+					Type:   cv1.ConditionReconcileCompleted,
+					Status: corev1.ConditionTrue,
+				},
+				{
+					Type:   cv1.ConditionRegistryCACertFound,
+					Status: corev1.ConditionFalse,
+				},
+				{
+					Type:   cv1.ConditionMigrationsCompleted,
+					Status: corev1.ConditionTrue,
+				},
+				{
+					Type:   cv1.ConditionGraphDataSignatureVerified,
+					Status: corev1.ConditionFalse,
+				},
 			},
 		},
 	}
@@ -105,12 +154,110 @@ func TestReconcile(t *testing.T) {
 	}
 }
 
+func TestReconcileClusterTrustedCAPerInstance(t *testing.T) {
+	foo := newDefaultUpdateService()
+	foo.UID = "foo-uid"
+	bar := newDefaultUpdateService()
+	bar.Name = "bar"
+	bar.UID = "bar-uid"
+	r := newTestReconciler(foo, bar, newSecret(), newProxy(configv1.ProxyStatus{HTTPProxy: "http://proxy.example.com:3128"}))
+
+	for _, instance := range []*cv1.UpdateService{foo, bar} {
+		if _, err := r.Reconcile(context.TODO(), newRequest(instance)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, instance := range []*cv1.UpdateService{foo, bar} {
+		config := &corev1.ConfigMap{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameClusterTrustedCAConfig(instance), Namespace: testNamespace}, config); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "true", config.Labels[injectTrustedCABundleLabel])
+		// deleting one UpdateService does not garbage-collect the CA of another
+		if assert.Len(t, config.OwnerReferences, 1) {
+			assert.Equal(t, instance.UID, config.OwnerReferences[0].UID)
+		}
+
+		deployment := &appsv1.Deployment{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(instance), Namespace: testNamespace}, deployment); err != nil {
+			t.Fatal(err)
+		}
+		mounted := ""
+		for _, volume := range deployment.Spec.Template.Spec.Volumes {
+			if volume.Name == NameClusterTrustedCAVolume {
+				mounted = volume.ConfigMap.Name
+			}
+		}
+		assert.Equal(t, config.Name, mounted)
+	}
+}
+
+func TestReconcileContinuesAfterFailures(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(updateservice, newSecret()).WithStatusSubresource(&cv1.UpdateService{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				switch obj.(type) {
+				case *networkingv1.NetworkPolicy:
+					return apierrors.NewForbidden(networkingv1.Resource("networkpolicies"), obj.GetName(), errors.New("denied"))
+				case *policyv1.PodDisruptionBudget:
+					return apierrors.NewForbidden(policyv1.Resource("poddisruptionbudgets"), obj.GetName(), errors.New("denied"))
+				}
+				return fakeApply(ctx, c, obj, patch, opts...)
+			},
+		}).Build()
+	request := newRequest(updateservice)
+
+	result, err := r.Reconcile(context.TODO(), request)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "NetworkPolicy:")
+	assert.Contains(t, err.Error(), "PodDisruptionBudget:")
+	assert.Equal(t, reconcile.Result{}, result, "failures should back off rather than requeue after the resync period")
+
+	// resources after the failing ones are still ensured
+	deployment := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: nameDeployment(updateservice), Namespace: testNamespace}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	route := &routev1.Route{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: namePolicyEngineRoute(updateservice), Namespace: testNamespace}, route); err != nil {
+		t.Fatal(err)
+	}
+
+	instance := &cv1.UpdateService{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []cv1.ResourceError{
+		{Resource: "PodDisruptionBudget", Reason: "ApplyPDBFailed", Message: `poddisruptionbudgets.policy "foo" is forbidden: denied`},
+		{Resource: "NetworkPolicy", Reason: "ApplyNetworkPolicyFailed", Message: `networkpolicies.networking.k8s.io "foo" is forbidden: denied`},
+	}, instance.Status.ResourceErrors)
+	completed := conditionsv1.FindStatusCondition(instance.Status.Conditions, cv1.ConditionReconcileCompleted)
+	if assert.NotNil(t, completed) {
+		assert.Equal(t, corev1.ConditionFalse, completed.Status)
+		assert.Equal(t, "MultipleResourcesFailed", completed.Reason)
+	}
+
+	// the errors are cleared once the resources are ensured
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(instance, newSecret()).WithStatusSubresource(&cv1.UpdateService{}).Build()
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, instance.Status.ResourceErrors)
+	assert.True(t, conditionsv1.IsStatusConditionTrue(instance.Status.Conditions, cv1.ConditionReconcileCompleted))
+}
+
 func TestEnsureConfig(t *testing.T) {
 	pullSecret := newSecret()
 	updateservice := newDefaultUpdateService()
 	r := newTestReconciler(updateservice)
 
-	resources, err := newKubeResources(updateservice, testOperandImage, pullSecret, nil, nil)
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, pullSecret, nil)
 	err = r.ensureConfig(context.TODO(), log, updateservice, resources)
 	if err != nil {
 		t.Fatal(err)
@@ -138,7 +285,7 @@ func TestEnsureEnvConfig(t *testing.T) {
 	updateservice := newDefaultUpdateService()
 	r := newTestReconciler(updateservice)
 
-	resources, err := newKubeResources(updateservice, testOperandImage, pullSecret, nil, nil)
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, pullSecret, nil)
 	err = r.ensureEnvConfig(context.TODO(), log, updateservice, resources)
 	if err != nil {
 		t.Fatal(err)
@@ -199,7 +346,7 @@ func TestEnsurePullSecret(t *testing.T) {
 				assert.Error(t, err)
 			}
 
-			resources, err := newKubeResources(updateservice, testOperandImage, ps, cm, nil)
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, ps, cm)
 
 			if !apierrors.IsNotFound(err) {
 				err = r.ensurePullSecret(context.TODO(), log, updateservice, resources)
@@ -306,7 +453,7 @@ func TestEnsureAdditionalTrustedCA(t *testing.T) {
 				return
 			}
 
-			resources, err := newKubeResources(updateservice, testOperandImage, ps, cm, nil)
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, ps, cm)
 
 			err = r.ensureAdditionalTrustedCA(context.TODO(), log, updateservice, resources)
 
@@ -380,7 +527,7 @@ func TestEnsureDeployment(t *testing.T) {
 				assert.Error(t, err)
 			}
 
-			resources, err := newKubeResources(updateservice, testOperandImage, ps, cm, nil)
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, ps, cm)
 
 			err = r.ensureDeployment(context.TODO(), log, updateservice, resources, "")
 			if err != nil {
@@ -419,12 +566,50 @@ func TestEnsureDeployment(t *testing.T) {
 	}
 }
 
+func TestEnsureGraphDataSHAReplacesUnlabeledPod(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	unlabeled := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "graph-data-tag-digest",
+			Namespace: updateservice.Namespace,
+		},
+	}
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(updateservice, unlabeled).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			// the cache only holds the pods labeled as graph-data pods
+			if pod, ok := obj.(*corev1.Pod); ok && pod.Labels[GraphDataPodLabel] != "true" {
+				return apierrors.NewNotFound(corev1.Resource("pods"), key.Name)
+			}
+			return nil
+		},
+	}).Build()
+
+	if _, err := r.ensureGraphDataSHA(context.TODO(), log, updateservice); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: unlabeled.Name, Namespace: unlabeled.Namespace}, &corev1.Pod{})
+	assert.True(t, apierrors.IsNotFound(err), "expected the unlabeled pod to be deleted, got %v", err)
+
+	if _, err := r.ensureGraphDataSHA(context.TODO(), log, updateservice); err != nil {
+		t.Fatal(err)
+	}
+	pod := &corev1.Pod{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: unlabeled.Name, Namespace: unlabeled.Namespace}, pod); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", pod.Labels[GraphDataPodLabel])
+}
+
 func TestEnsureGraphBuilderService(t *testing.T) {
 	pullSecret := newSecret()
 	updateservice := newDefaultUpdateService()
 	r := newTestReconciler(updateservice)
 
-	resources, err := newKubeResources(updateservice, testOperandImage, pullSecret, nil, nil)
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, pullSecret, nil)
 	err = r.ensureGraphBuilderService(context.TODO(), log, updateservice, resources)
 	if err != nil {
 		t.Fatal(err)
@@ -447,7 +632,7 @@ func TestEnsurePolicyEngineService(t *testing.T) {
 	updateservice := newDefaultUpdateService()
 	r := newTestReconciler(updateservice)
 
-	resources, err := newKubeResources(updateservice, testOperandImage, pullSecret, nil, nil)
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, pullSecret, nil)
 	err = r.ensurePolicyEngineService(context.TODO(), log, updateservice, resources)
 	if err != nil {
 		t.Fatal(err)
@@ -512,7 +697,7 @@ func TestEnsurePodDisruptionBudget(t *testing.T) {
 				assert.Error(t, err)
 			}
 
-			resources, err := newKubeResources(updateservice, testOperandImage, ps, cm, nil)
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, ps, cm)
 			err = r.ensurePodDisruptionBudget(context.TODO(), log, updateservice, resources)
 			if err != nil {
 				t.Fatal(err)
@@ -612,7 +797,7 @@ func TestEnsurePolicyEngineRoute(t *testing.T) {
 				assert.Error(t, err)
 			}
 
-			resources, err := newKubeResources(updateservice, testOperandImage, ps, cm, nil)
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, ps, cm)
 			err = r.ensurePolicyEngineRoute(context.TODO(), log, updateservice, resources)
 			if err != nil {
 				t.Fatal(err)
@@ -634,11 +819,58 @@ func TestEnsurePolicyEngineRoute(t *testing.T) {
 	}
 }
 
-func TestEnsureNetworkPolicy(t *testing.T) {
-	t.Setenv("HTTP_PROXY", "")
-	t.Setenv("HTTPS_PROXY", "")
-	t.Setenv("NO_PROXY", "")
+func TestEnsurePolicyEngineRouteApply(t *testing.T) {
+	updateservice := newDefaultUpdateService()
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, newSecret(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the host and a custom certificate were set by hand, and the target
+	// was changed
+	existing := resources.policyEngineRoute.DeepCopy()
+	existing.Spec.Host = "updates.example.com"
+	existing.Spec.TLS = &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge, Certificate: "cert", Key: "key"}
+	existing.Spec.To.Name = "changed"
+
+	var applied *routev1.Route
+	r := newTestReconciler()
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(updateservice, existing).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if route, ok := obj.(*routev1.Route); ok && patch.Type() == types.ApplyPatchType {
+					applied = route.DeepCopy()
+				}
+				return fakeApply(ctx, c, obj, patch, opts...)
+			},
+		}).Build()
+
+	if err := r.ensurePolicyEngineRoute(context.TODO(), log, updateservice, resources); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, applied, "the Route should be applied") {
+		assert.Empty(t, applied.Spec.Host, "the host should not be applied")
+		assert.Nil(t, applied.Spec.TLS, "the TLS configuration should not be applied")
+	}
+	found := &routev1.Route{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: existing.Name, Namespace: existing.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, namePolicyEngineService(updateservice), found.Spec.To.Name)
+	assert.Equal(t, "updates.example.com", found.Spec.Host)
+	assert.Equal(t, existing.Spec.TLS, found.Spec.TLS)
+
+	// a failing apply fails the step
+	r.Client = newFakeClientBuilder().WithRuntimeObjects(updateservice, existing).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				return apierrors.NewForbidden(routev1.Resource("routes"), obj.GetName(), errors.New("denied"))
+			},
+		}).Build()
+	err = r.ensurePolicyEngineRoute(context.TODO(), log, updateservice, resources)
+	assert.True(t, apierrors.IsForbidden(err), "expected the apply error, got %v", err)
+}
 
+func TestEnsureNetworkPolicy(t *testing.T) {
 	tests := []struct {
 		name                 string
 		existingObjs         []runtime.Object
@@ -683,7 +915,7 @@ func TestEnsureNetworkPolicy(t *testing.T) {
 				assert.Error(t, err)
 			}
 
-			resources, err := newKubeResources(updateservice, testOperandImage, ps, nil, nil)
+			resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, ps, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -701,10 +933,17 @@ func TestEnsureNetworkPolicy(t *testing.T) {
 
 			verifyOwnerReference(t, found.ObjectMeta.OwnerReferences[0], updateservice)
 
-			// Ingress: router -> policy-engine
-			assert.Equal(t, 1, len(found.Spec.Ingress), "should have 1 ingress rule")
+			// Ingress: router -> policy-engine, operator -> policy-engine and graph-builder status,
+			// monitoring -> graph-builder and policy-engine status
+			assert.Equal(t, 3, len(found.Spec.Ingress), "should have 3 ingress rules")
 			assert.Equal(t, intstr.FromString("policy-engine"), *found.Spec.Ingress[0].Ports[0].Port)
 			assert.Contains(t, found.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels, "policy-group.network.openshift.io/ingress")
+			assert.Equal(t, intstr.FromString("policy-engine"), *found.Spec.Ingress[1].Ports[0].Port)
+			assert.Equal(t, intstr.FromString("status-gb"), *found.Spec.Ingress[1].Ports[1].Port)
+			assert.Equal(t, operatorName, found.Spec.Ingress[1].From[0].PodSelector.MatchLabels["name"])
+			assert.Equal(t, monitoringNamespace, found.Spec.Ingress[2].From[0].NamespaceSelector.MatchLabels[corev1.LabelMetadataName])
+			assert.Equal(t, intstr.FromString("status-gb"), *found.Spec.Ingress[2].Ports[0].Port)
+			assert.Equal(t, intstr.FromString("status-pe"), *found.Spec.Ingress[2].Ports[1].Port)
 
 			// Egress: registry + DNS
 			assert.Equal(t, test.expectedEgress, len(found.Spec.Egress), "should have expected egress rules")
@@ -728,10 +967,6 @@ func TestEnsureNetworkPolicy(t *testing.T) {
 }
 
 func TestEnsureNetworkPolicyUpdatesOnPortChange(t *testing.T) {
-	t.Setenv("HTTP_PROXY", "")
-	t.Setenv("HTTPS_PROXY", "")
-	t.Setenv("NO_PROXY", "")
-
 	pullSecret := newSecret()
 	updateservice := &cv1.UpdateService{
 		TypeMeta: metav1.TypeMeta{
@@ -750,7 +985,7 @@ func TestEnsureNetworkPolicyUpdatesOnPortChange(t *testing.T) {
 	}
 
 	// Get expected NetworkPolicy (port 443 for quay.io)
-	resources, err := newKubeResources(updateservice, testOperandImage, pullSecret, nil, nil)
+	resources, err := newKubeResources(updateservice, testOperandImage, testNamespace, nil, pullSecret, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -791,7 +1026,7 @@ func newRequest(updateservice *cv1.UpdateService) reconcile.Request {
 }
 
 func newTestReconciler(initObjs ...runtime.Object) *UpdateServiceReconciler {
-	c := fake.NewClientBuilder().WithRuntimeObjects(initObjs...).WithStatusSubresource(&cv1.UpdateService{}).Build()
+	c := newFakeClientBuilder().WithRuntimeObjects(initObjs...).WithStatusSubresource(&cv1.UpdateService{}).Build()
 	return &UpdateServiceReconciler{
 		Client:            c,
 		Scheme:            scheme.Scheme,
@@ -830,6 +1065,15 @@ func newImage() *configv1.Image {
 	}
 }
 
+func newProxy(status configv1.ProxyStatus) *configv1.Proxy {
+	return &configv1.Proxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ProxyConfigName,
+		},
+		Status: status,
+	}
+}
+
 func newConfigMap() *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
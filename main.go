@@ -1,49 +1,57 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	// Embed the time zone database for UpdateService rollout schedules.
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	configv1 "github.com/openshift/api/config/v1"
+	consolev1 "github.com/openshift/api/console/v1"
 	routev1 "github.com/openshift/api/route/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 
 	updateservicev1 "github.com/openshift/cincinnati-operator/api/v1"
 	"github.com/openshift/cincinnati-operator/controllers"
 	"github.com/openshift/cincinnati-operator/version"
 
 	// to ensure that exec-entrypoint and run can make use of them.
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
 )
 
-// Change below variables to serve metrics on different host or port.
-var (
-	metricsHost               = "0.0.0.0"
-	metricsPort         int32 = 8383
-	operatorMetricsPort int32 = 8686
-)
-
 var (
 	scheme = apiruntime.NewScheme()
 	log    = ctrl.Log.WithName("cmd")
 )
 
+// leaderElectionID is the name of the Lease held by the leading replica.
+const leaderElectionID = "48ad1930.openshift.io"
+
 func init() {
 	utilruntime.Must(configv1.AddToScheme(scheme))
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(routev1.AddToScheme(scheme))
+	utilruntime.Must(consolev1.AddToScheme(scheme))
+	utilruntime.Must(monitoringv1.AddToScheme(scheme))
 	utilruntime.Must(updateservicev1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
@@ -63,16 +71,95 @@ func getEnvVar(envVarName string) (string, error) {
 	return val, nil
 }
 
+// tlsVersions are the values accepted by --metrics-tls-min-version.
+var tlsVersions = map[string]uint16{
+	"VersionTLS12": tls.VersionTLS12,
+	"VersionTLS13": tls.VersionTLS13,
+}
+
+// metricsTLSOpts returns the TLS options of the metrics server for the given
+// minimum version and comma-separated IANA cipher suite names.
+func metricsTLSOpts(minVersion, cipherSuites string, enableHTTP2 bool) ([]func(*tls.Config), error) {
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q", minVersion)
+	}
+	var ciphers []uint16
+	if cipherSuites != "" {
+		known := map[string]uint16{}
+		for _, suite := range tls.CipherSuites() {
+			known[suite.Name] = suite.ID
+		}
+		for _, name := range strings.Split(cipherSuites, ",") {
+			id, ok := known[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unsupported TLS cipher suite %q", name)
+			}
+			ciphers = append(ciphers, id)
+		}
+	}
+
+	return []func(*tls.Config){func(c *tls.Config) {
+		c.MinVersion = version
+		if len(ciphers) > 0 {
+			c.CipherSuites = ciphers
+		}
+		// HTTP/2 is disabled by default because of the HTTP/2 Stream
+		// Cancellation and Rapid Reset CVEs.
+		if !enableHTTP2 {
+			c.NextProtos = []string{"http/1.1"}
+		}
+	}}, nil
+}
+
 func main() {
 	var metricsAddr string
+	var secureMetrics bool
+	var metricsCertDir, metricsCertName, metricsKeyName string
+	var metricsTLSMinVersion, metricsTLSCipherSuites string
+	var enableHTTP2 bool
+	var probeAddr string
 	var enableLeaderElection bool
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8443", "The address the metric endpoint binds to. "+
+		"Use :8080 for HTTP, or 0 to disable the metrics endpoint.")
+	flag.BoolVar(&secureMetrics, "metrics-secure", true,
+		"Serve metrics over HTTPS, to clients that are authenticated and authorized to get /metrics.")
+	flag.StringVar(&metricsCertDir, "metrics-cert-dir", "",
+		"The directory holding the metrics serving certificate. A self-signed certificate is generated when empty.")
+	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The metrics serving certificate file name.")
+	flag.StringVar(&metricsKeyName, "metrics-key-name", "tls.key", "The metrics serving key file name.")
+	flag.StringVar(&metricsTLSMinVersion, "metrics-tls-min-version", "VersionTLS12",
+		"The minimum TLS version of the metrics endpoint, VersionTLS12 or VersionTLS13.")
+	flag.StringVar(&metricsTLSCipherSuites, "metrics-tls-cipher-suites", "",
+		"Comma-separated IANA names of the TLS 1.2 cipher suites of the metrics endpoint. The Go defaults are used when empty.")
+	flag.BoolVar(&enableHTTP2, "enable-http2", false, "Serve metrics over HTTP/2 as well as HTTP/1.1.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the health probe endpoint binds to.")
+	// OLM sets WATCH_NAMESPACE to the target namespaces of the OperatorGroup
+	watchNamespaces, watchNamespacesSet := os.LookupEnv("WATCH_NAMESPACE")
+	flag.Func("watch-namespaces", "Comma-separated namespaces whose UpdateServices are reconciled, "+
+		"or empty for all namespaces. Defaults to WATCH_NAMESPACE when set, and to the operator's namespace otherwise.",
+		func(value string) error {
+			watchNamespaces, watchNamespacesSet = value, true
+			return nil
+		})
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	logOptions := zap.Options{
+		TimeEncoder: zapcore.ISO8601TimeEncoder,
+	}
+	logOptions.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	// --zap-log-level sets its own AtomicLevel; otherwise log at info. The
+	// level can be changed later through the operator ConfigMap.
+	logLevel, ok := logOptions.Level.(uberzap.AtomicLevel)
+	if !ok {
+		logLevel = uberzap.NewAtomicLevelAt(zapcore.InfoLevel)
+		logOptions.Level = logLevel
+	}
+	defaultLogLevel := logLevel.Level()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&logOptions)))
 
 	printVersion()
 
@@ -86,19 +173,35 @@ func main() {
 		log.Error(err, "POD_NAMESPACE must be set; unable to start manager")
 		os.Exit(1)
 	}
+	namespaces := []string{podNamespace}
+	if watchNamespacesSet {
+		namespaces = controllers.ParseWatchNamespaces(watchNamespaces)
+	}
+	tlsOpts, err := metricsTLSOpts(metricsTLSMinVersion, metricsTLSCipherSuites, enableHTTP2)
+	if err != nil {
+		log.Error(err, "invalid metrics TLS settings; unable to start manager")
+		os.Exit(1)
+	}
+	metricsOptions := metricsserver.Options{
+		BindAddress:   metricsAddr,
+		SecureServing: secureMetrics,
+		CertDir:       metricsCertDir,
+		CertName:      metricsCertName,
+		KeyName:       metricsKeyName,
+		TLSOpts:       tlsOpts,
+	}
+	if secureMetrics {
+		// authenticate scrapers with TokenReviews and authorize them with
+		// SubjectAccessReviews for the /metrics non-resource URL
+		metricsOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
+	}
 	options := ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress: metricsAddr,
-		},
-		LeaderElection:   enableLeaderElection,
-		LeaderElectionID: "48ad1930.openshift.io",
-		Cache: cache.Options{
-			DefaultNamespaces: map[string]cache.Config{
-				podNamespace:                         {},
-				controllers.OpenshiftConfigNamespace: {},
-			},
-		},
+		Scheme:                 scheme,
+		Metrics:                metricsOptions,
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       leaderElectionID,
+		Cache:                  controllers.CacheOptions(podNamespace, namespaces),
 	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
@@ -106,21 +209,61 @@ func main() {
 		log.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
-	if err = (&controllers.UpdateServiceReconciler{
+	ctx := ctrl.SetupSignalHandler()
+	tracerProvider, err := controllers.NewTracerProvider(ctx)
+	if err != nil {
+		log.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	reconciler := &controllers.UpdateServiceReconciler{
 		Client:            mgr.GetClient(),
 		Log:               ctrl.Log.WithName("controllers").WithName("UpdateService"),
 		Scheme:            mgr.GetScheme(),
 		OperandImage:      operandImage,
 		OperatorNamespace: podNamespace,
-	}).SetupWithManager(mgr, podNamespace); err != nil {
+		WatchNamespaces:   namespaces,
+	}
+	if tracerProvider != nil {
+		log.Info("Exporting reconcile traces over OTLP")
+		reconciler.TracerProvider = tracerProvider
+		defer func() {
+			if err := tracerProvider.Shutdown(context.Background()); err != nil {
+				log.Error(err, "unable to flush traces")
+			}
+		}()
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "UpdateService")
 		os.Exit(1)
 	}
+	if err = (&controllers.LogLevelReconciler{
+		Client:    mgr.GetClient(),
+		Namespace: podNamespace,
+		Level:     logLevel,
+		Default:   defaultLogLevel,
+	}).SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "LogLevel")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
-	log.Info(fmt.Sprintf("Starting in Namespace %s...", podNamespace))
+	if err := mgr.AddHealthzCheck("reconcile", reconciler.LivenessCheck(mgr.Elected())); err != nil {
+		log.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("informers", controllers.CacheSyncCheck(mgr.GetCache())); err != nil {
+		log.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	lease := types.NamespacedName{Namespace: podNamespace, Name: leaderElectionID}
+	if err := mgr.AddReadyzCheck("leader-election", controllers.LeaderElectionCheck(mgr.Elected(), mgr.GetAPIReader(), lease)); err != nil {
+		log.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	log.Info(fmt.Sprintf("Starting in Namespace %s...", podNamespace), "WatchNamespaces", namespaces)
 
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		log.Error(err, "Manager exited non-zero")
 		os.Exit(1)
 	}